@@ -0,0 +1,110 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Bind returns a compare function that always uses the supplied expected values,
+// ignoring any expected values it is called with.
+// Use it to give each operand of And, Or, Not and Implies its own expected values, e.g.
+//
+//	Or(IsNull, Bind(IsOneOf, NewStringResults("Standard", "Premium")...))
+func Bind(cmpFn ResultCompareFunc, expected ...cty.Value) ResultCompareFunc {
+	return func(got cty.Value, _ ...cty.Value) (bool, string, error) {
+		return cmpFn(got, expected...)
+	}
+}
+
+// And returns a compare function that succeeds if all of the compare functions succeed.
+// Operands that are not bound with Bind are called with the expected values passed to the returned function.
+// The failure messages of all failing operands are combined.
+// This function panics if no compare functions are given.
+func And(cmpFns ...ResultCompareFunc) ResultCompareFunc {
+	if len(cmpFns) == 0 {
+		panic("And requires at least one compare function")
+	}
+	return func(got cty.Value, expected ...cty.Value) (bool, string, error) {
+		var msgs []string
+		for _, cmpFn := range cmpFns {
+			ok, msg, err := cmpFn(got, expected...)
+			if err != nil {
+				return false, "", fmt.Errorf("and: %w", err)
+			}
+			if !ok {
+				msgs = append(msgs, msg)
+			}
+		}
+		if len(msgs) > 0 {
+			return false, strings.Join(msgs, "; "), nil
+		}
+		return true, "", nil
+	}
+}
+
+// Or returns a compare function that succeeds if any of the compare functions succeed.
+// Operands that are not bound with Bind are called with the expected values passed to the returned function.
+// If all operands fail, their failure messages are combined.
+// This function panics if no compare functions are given.
+func Or(cmpFns ...ResultCompareFunc) ResultCompareFunc {
+	if len(cmpFns) == 0 {
+		panic("Or requires at least one compare function")
+	}
+	return func(got cty.Value, expected ...cty.Value) (bool, string, error) {
+		msgs := make([]string, 0, len(cmpFns))
+		for _, cmpFn := range cmpFns {
+			ok, msg, err := cmpFn(got, expected...)
+			if err != nil {
+				return false, "", fmt.Errorf("or: %w", err)
+			}
+			if ok {
+				return true, "", nil
+			}
+			msgs = append(msgs, fmt.Sprintf("(%s)", msg))
+		}
+		return false, fmt.Sprintf("none of the conditions were met: %s", strings.Join(msgs, " or ")), nil
+	}
+}
+
+// Not returns a compare function that succeeds if the compare function fails.
+// If the operand is not bound with Bind it is called with the expected values passed to the returned function.
+func Not(cmpFn ResultCompareFunc) ResultCompareFunc {
+	return func(got cty.Value, expected ...cty.Value) (bool, string, error) {
+		ok, _, err := cmpFn(got, expected...)
+		if err != nil {
+			return false, "", fmt.Errorf("not: %w", err)
+		}
+		if ok {
+			return false, fmt.Sprintf("returned value `%s` met a condition that it was expected not to", fmtCty(got)), nil
+		}
+		return true, "", nil
+	}
+}
+
+// Implies returns a compare function that succeeds if the condition fails, or if both the condition and the consequence succeed.
+// E.g. Implies(IsNotNull, Bind(IsOneOf, NewStringResults("Standard")...)) checks the value only if it is set.
+// Operands that are not bound with Bind are called with the expected values passed to the returned function.
+func Implies(condition, consequence ResultCompareFunc) ResultCompareFunc {
+	return func(got cty.Value, expected ...cty.Value) (bool, string, error) {
+		ok, _, err := condition(got, expected...)
+		if err != nil {
+			return false, "", fmt.Errorf("implies condition: %w", err)
+		}
+		if !ok {
+			return true, "", nil
+		}
+		ok, msg, err := consequence(got, expected...)
+		if err != nil {
+			return false, "", fmt.Errorf("implies consequence: %w", err)
+		}
+		if !ok {
+			return false, msg, nil
+		}
+		return true, "", nil
+	}
+}
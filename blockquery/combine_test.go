@@ -0,0 +1,120 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestCombinators(t *testing.T) {
+	standardOrPremium := Bind(IsOneOf, NewStringResults("Standard", "Premium")...)
+	errBoom := errors.New("boom")
+	failWithErr := func(cty.Value, ...cty.Value) (bool, string, error) {
+		return false, "", errBoom
+	}
+	testCases := []struct {
+		desc     string
+		cmpFn    ResultCompareFunc
+		got      cty.Value
+		expected []cty.Value
+		ok       bool
+		msg      string
+		err      error
+	}{
+		{
+			desc:  "or first passes",
+			cmpFn: Or(IsNull, standardOrPremium),
+			got:   cty.NullVal(cty.String),
+			ok:    true,
+		},
+		{
+			desc:  "or second passes",
+			cmpFn: Or(IsNull, standardOrPremium),
+			got:   cty.StringVal("Premium"),
+			ok:    true,
+		},
+		{
+			desc:  "or none pass",
+			cmpFn: Or(IsNull, standardOrPremium),
+			got:   cty.StringVal("Basic"),
+			msg:   "none of the conditions were met: (returned value is not null but expected to be) or (returned value `Basic` not in expected values `[Standard Premium]`)",
+		},
+		{
+			desc:  "and all pass",
+			cmpFn: And(IsNotNull, standardOrPremium),
+			got:   cty.StringVal("Standard"),
+			ok:    true,
+		},
+		{
+			desc:  "and one fails",
+			cmpFn: And(IsNotNull, standardOrPremium),
+			got:   cty.StringVal("Basic"),
+			msg:   "returned value `Basic` not in expected values `[Standard Premium]`",
+		},
+		{
+			desc:     "unbound operand uses outer expected values",
+			cmpFn:    And(IsNotNull, IsOneOf),
+			got:      cty.StringVal("Basic"),
+			expected: NewStringResults("Basic"),
+			ok:       true,
+		},
+		{
+			desc:  "not passes",
+			cmpFn: Not(standardOrPremium),
+			got:   cty.StringVal("Basic"),
+			ok:    true,
+		},
+		{
+			desc:  "not fails",
+			cmpFn: Not(standardOrPremium),
+			got:   cty.StringVal("Standard"),
+			msg:   "returned value `Standard` met a condition that it was expected not to",
+		},
+		{
+			desc:  "implies condition not met",
+			cmpFn: Implies(IsNotNull, standardOrPremium),
+			got:   cty.NullVal(cty.String),
+			ok:    true,
+		},
+		{
+			desc:  "implies consequence met",
+			cmpFn: Implies(IsNotNull, standardOrPremium),
+			got:   cty.StringVal("Standard"),
+			ok:    true,
+		},
+		{
+			desc:  "implies consequence not met",
+			cmpFn: Implies(IsNotNull, standardOrPremium),
+			got:   cty.StringVal("Basic"),
+			msg:   "returned value `Basic` not in expected values `[Standard Premium]`",
+		},
+		{
+			desc:  "error propagates",
+			cmpFn: Or(IsNull, Not(failWithErr)),
+			got:   cty.StringVal("Basic"),
+			err:   errBoom,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ok, msg, err := tC.cmpFn(tC.got, tC.expected...)
+			if tC.err != nil {
+				require.ErrorIs(t, err, tC.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tC.ok, ok)
+			require.Equal(t, tC.msg, msg)
+		})
+	}
+}
+
+func TestCombinatorsWithoutOperands(t *testing.T) {
+	require.Panics(t, func() { And() })
+	require.Panics(t, func() { Or() })
+}
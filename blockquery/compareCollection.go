@@ -0,0 +1,183 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Contains is a compare function that checks if the collection contains at least one of the expected values.
+// E.g. use NewStringResults("1") to check that a list of zones includes zone 1.
+func Contains(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	elems, err := collectionElements(got)
+	if err != nil {
+		return collectionFailure(err)
+	}
+	for _, e := range elems {
+		if compareResults(e, expected) {
+			return true, "", nil
+		}
+	}
+	return false, fmt.Sprintf("returned values `%s` do not contain any of `%s`", fmtCty(got), fmtCty(cty.TupleVal(expected))), nil
+}
+
+// ContainsAll is a compare function that checks if the collection contains all of the expected values.
+// E.g. use NewStringResults("1", "2", "3") to check that a list of zones includes zones 1, 2 and 3.
+func ContainsAll(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	missing, err := missingElements(got, expected)
+	if err != nil {
+		return collectionFailure(err)
+	}
+	if len(missing) > 0 {
+		return false, fmt.Sprintf("returned values `%s` do not contain `%s`", fmtCty(got), fmtCty(cty.TupleVal(missing))), nil
+	}
+	return true, "", nil
+}
+
+// IsSupersetOf is a compare function that checks if the collection is a superset of the expected values.
+// It is equivalent to ContainsAll.
+func IsSupersetOf(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	missing, err := missingElements(got, expected)
+	if err != nil {
+		return collectionFailure(err)
+	}
+	if len(missing) > 0 {
+		return false, fmt.Sprintf("returned values `%s` are not a superset of `%s`, missing `%s`", fmtCty(got), fmtCty(cty.TupleVal(expected)), fmtCty(cty.TupleVal(missing))), nil
+	}
+	return true, "", nil
+}
+
+// IsSubsetOf is a compare function that checks if every element of the collection is one of the expected values.
+func IsSubsetOf(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	elems, err := collectionElements(got)
+	if err != nil {
+		return collectionFailure(err)
+	}
+	var extra []cty.Value
+	for _, e := range elems {
+		if !compareResults(e, expected) {
+			extra = append(extra, e)
+		}
+	}
+	if len(extra) > 0 {
+		return false, fmt.Sprintf("returned values `%s` are not a subset of `%s`, unexpected `%s`", fmtCty(got), fmtCty(cty.TupleVal(expected)), fmtCty(cty.TupleVal(extra))), nil
+	}
+	return true, "", nil
+}
+
+// LengthEquals is a compare function that checks if the collection has exactly the expected number of elements.
+// E.g. use NewIntResults(3) as the expected value.
+func LengthEquals(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	elems, n, err := collectionLength(got, expected)
+	if err != nil {
+		return collectionFailure(err)
+	}
+	if len(elems) != n {
+		return false, fmt.Sprintf("returned values `%s` have length %d, expected %d", fmtCty(got), len(elems), n), nil
+	}
+	return true, "", nil
+}
+
+// LengthAtLeast is a compare function that checks if the collection has at least the expected number of elements.
+// E.g. use NewIntResults(1) as the expected value to check that a collection is not empty.
+func LengthAtLeast(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	elems, n, err := collectionLength(got, expected)
+	if err != nil {
+		return collectionFailure(err)
+	}
+	if len(elems) < n {
+		return false, fmt.Sprintf("returned values `%s` have length %d, expected at least %d", fmtCty(got), len(elems), n), nil
+	}
+	return true, "", nil
+}
+
+// IsEmpty is a compare function that checks if the collection has no elements.
+// A null collection is considered empty.
+func IsEmpty(got cty.Value, _ ...cty.Value) (bool, string, error) {
+	elems, err := collectionElements(got)
+	if err != nil {
+		return collectionFailure(err)
+	}
+	if len(elems) > 0 {
+		return false, fmt.Sprintf("returned values `%s` are not empty", fmtCty(got)), nil
+	}
+	return true, "", nil
+}
+
+// errUnknownCollection is returned by collectionElements for a collection that is not known until apply.
+var errUnknownCollection = errors.New("collection is unknown")
+
+// collectionElements returns the elements of a list, tuple or set.
+// A null collection has no elements. An unknown collection, including cty.DynamicVal, returns errUnknownCollection.
+func collectionElements(got cty.Value) ([]cty.Value, error) {
+	if !got.IsKnown() {
+		return nil, errUnknownCollection
+	}
+	ty := got.Type()
+	if !ty.IsListType() && !ty.IsTupleType() && !ty.IsSetType() {
+		return nil, fmt.Errorf("expected a list, tuple or set but got %s", ty.FriendlyName())
+	}
+	if got.IsNull() {
+		return nil, nil
+	}
+	elems := make([]cty.Value, 0, got.LengthInt())
+	it := got.ElementIterator()
+	for it.Next() {
+		_, v := it.Element()
+		elems = append(elems, v)
+	}
+	return elems, nil
+}
+
+// collectionFailure returns the result of a compare function whose collection could not be read.
+// An unknown collection fails like any other unknown result rather than returning an error,
+// so that the caller's policy for unknown values decides what to do with it.
+func collectionFailure(err error) (bool, string, error) {
+	if errors.Is(err, errUnknownCollection) {
+		return false, "returned value is unknown", nil
+	}
+	return false, "", err
+}
+
+// missingElements returns the expected values that are not in the collection.
+func missingElements(got cty.Value, expected []cty.Value) ([]cty.Value, error) {
+	elems, err := collectionElements(got)
+	if err != nil {
+		return nil, err
+	}
+	var missing []cty.Value
+	for _, want := range expected {
+		found := false
+		for _, e := range elems {
+			if compareResults(e, []cty.Value{want}) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, want)
+		}
+	}
+	return missing, nil
+}
+
+// collectionLength returns the elements of the collection and the expected length.
+func collectionLength(got cty.Value, expected []cty.Value) ([]cty.Value, int, error) {
+	if len(expected) != 1 {
+		return nil, 0, fmt.Errorf("expected a single length but got %d values", len(expected))
+	}
+	n, ok := asNumber(expected[0])
+	if !ok || !n.IsInt() {
+		return nil, 0, fmt.Errorf("length `%s` is not an integer", fmtCty(expected[0]))
+	}
+	elems, err := collectionElements(got)
+	if err != nil {
+		return nil, 0, err
+	}
+	i, _ := n.Int64()
+	return elems, int(i), nil
+}
@@ -0,0 +1,186 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestCollectionCompareFuncs(t *testing.T) {
+	zones := cty.TupleVal([]cty.Value{cty.StringVal("1"), cty.StringVal("2")})
+	zoneSet := cty.SetVal([]cty.Value{cty.StringVal("1"), cty.StringVal("2"), cty.StringVal("3")})
+	testCases := []struct {
+		desc      string
+		cmpFn     ResultCompareFunc
+		got       cty.Value
+		expected  []cty.Value
+		ok        bool
+		msg       string
+		expectErr bool
+	}{
+		{
+			desc:     "contains",
+			cmpFn:    Contains,
+			got:      zones,
+			expected: NewStringResults("2", "4"),
+			ok:       true,
+		},
+		{
+			desc:     "contains number converted",
+			cmpFn:    Contains,
+			got:      zones,
+			expected: NewIntResults(1),
+			ok:       true,
+		},
+		{
+			desc:     "does not contain",
+			cmpFn:    Contains,
+			got:      zones,
+			expected: NewStringResults("3"),
+			msg:      "returned values `[1 2]` do not contain any of `[3]`",
+		},
+		{
+			desc:     "contains all in set",
+			cmpFn:    ContainsAll,
+			got:      zoneSet,
+			expected: NewStringResults("1", "2", "3"),
+			ok:       true,
+		},
+		{
+			desc:     "does not contain all",
+			cmpFn:    ContainsAll,
+			got:      zones,
+			expected: NewStringResults("1", "2", "3"),
+			msg:      "returned values `[1 2]` do not contain `[3]`",
+		},
+		{
+			desc:     "superset",
+			cmpFn:    IsSupersetOf,
+			got:      zoneSet,
+			expected: NewStringResults("1", "3"),
+			ok:       true,
+		},
+		{
+			desc:     "not superset",
+			cmpFn:    IsSupersetOf,
+			got:      zones,
+			expected: NewStringResults("1", "3"),
+			msg:      "returned values `[1 2]` are not a superset of `[1 3]`, missing `[3]`",
+		},
+		{
+			desc:     "subset",
+			cmpFn:    IsSubsetOf,
+			got:      zones,
+			expected: NewStringResults("1", "2", "3"),
+			ok:       true,
+		},
+		{
+			desc:     "not subset",
+			cmpFn:    IsSubsetOf,
+			got:      zoneSet,
+			expected: NewStringResults("1", "2"),
+			msg:      "returned values `[1 2 3]` are not a subset of `[1 2]`, unexpected `[3]`",
+		},
+		{
+			desc:     "length equals",
+			cmpFn:    LengthEquals,
+			got:      zones,
+			expected: NewIntResults(2),
+			ok:       true,
+		},
+		{
+			desc:     "length not equal",
+			cmpFn:    LengthEquals,
+			got:      zoneSet,
+			expected: NewIntResults(2),
+			msg:      "returned values `[1 2 3]` have length 3, expected 2",
+		},
+		{
+			desc:     "length at least",
+			cmpFn:    LengthAtLeast,
+			got:      cty.ListValEmpty(cty.String),
+			expected: NewIntResults(1),
+			msg:      "returned values `[]` have length 0, expected at least 1",
+		},
+		{
+			desc:      "length not an integer",
+			cmpFn:     LengthAtLeast,
+			got:       zones,
+			expected:  NewFloatResults(1.5),
+			expectErr: true,
+		},
+		{
+			desc:  "empty",
+			cmpFn: IsEmpty,
+			got:   cty.EmptyTupleVal,
+			ok:    true,
+		},
+		{
+			desc:  "null is empty",
+			cmpFn: IsEmpty,
+			got:   cty.NullVal(cty.List(cty.String)),
+			ok:    true,
+		},
+		{
+			desc:  "not empty",
+			cmpFn: IsEmpty,
+			got:   zones,
+			msg:   "returned values `[1 2]` are not empty",
+		},
+		{
+			desc:      "not a collection",
+			cmpFn:     IsEmpty,
+			got:       cty.StringVal("a"),
+			expectErr: true,
+		},
+		{
+			desc:     "contains dynamic value",
+			cmpFn:    Contains,
+			got:      cty.DynamicVal,
+			expected: NewStringResults("1"),
+			msg:      "returned value is unknown",
+		},
+		{
+			desc:  "unknown collection is not empty",
+			cmpFn: IsEmpty,
+			got:   cty.UnknownVal(cty.List(cty.String)),
+			msg:   "returned value is unknown",
+		},
+		{
+			desc:     "length of unknown collection",
+			cmpFn:    LengthAtLeast,
+			got:      cty.UnknownVal(cty.Set(cty.String)),
+			expected: NewIntResults(1),
+			msg:      "returned value is unknown",
+		},
+		{
+			desc:     "each of dynamic value",
+			cmpFn:    EachIsOneOf,
+			got:      cty.DynamicVal,
+			expected: NewStringResults("1"),
+			msg:      "returned value is unknown",
+		},
+		{
+			desc:  "each of dynamic value matches glob",
+			cmpFn: EachMatchesGlob("1"),
+			got:   cty.DynamicVal,
+			msg:   "returned value is unknown",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ok, msg, err := tC.cmpFn(tC.got, tC.expected...)
+			if tC.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tC.ok, ok)
+			require.Equal(t, tC.msg, msg)
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// numberCheck describes a numeric comparison against one or more bounds.
+type numberCheck struct {
+	bounds int                                     // The number of expected values required.
+	desc   func(bounds []cty.Value) string         // Describes the condition for failure messages, e.g. "greater than `7`".
+	check  func(v *big.Float, b []*big.Float) bool // Reports whether the value satisfies the condition.
+}
+
+var (
+	greaterThan = numberCheck{
+		bounds: 1,
+		desc:   func(b []cty.Value) string { return fmt.Sprintf("greater than `%s`", fmtCty(b[0])) },
+		check:  func(v *big.Float, b []*big.Float) bool { return v.Cmp(b[0]) > 0 },
+	}
+	greaterThanOrEqual = numberCheck{
+		bounds: 1,
+		desc:   func(b []cty.Value) string { return fmt.Sprintf("greater than or equal to `%s`", fmtCty(b[0])) },
+		check:  func(v *big.Float, b []*big.Float) bool { return v.Cmp(b[0]) >= 0 },
+	}
+	lessThan = numberCheck{
+		bounds: 1,
+		desc:   func(b []cty.Value) string { return fmt.Sprintf("less than `%s`", fmtCty(b[0])) },
+		check:  func(v *big.Float, b []*big.Float) bool { return v.Cmp(b[0]) < 0 },
+	}
+	lessThanOrEqual = numberCheck{
+		bounds: 1,
+		desc:   func(b []cty.Value) string { return fmt.Sprintf("less than or equal to `%s`", fmtCty(b[0])) },
+		check:  func(v *big.Float, b []*big.Float) bool { return v.Cmp(b[0]) <= 0 },
+	}
+	inRange = numberCheck{
+		bounds: 2,
+		desc: func(b []cty.Value) string {
+			return fmt.Sprintf("between `%s` and `%s` (inclusive)", fmtCty(b[0]), fmtCty(b[1]))
+		},
+		check: func(v *big.Float, b []*big.Float) bool { return v.Cmp(b[0]) >= 0 && v.Cmp(b[1]) <= 0 },
+	}
+	inRangeExclusive = numberCheck{
+		bounds: 2,
+		desc: func(b []cty.Value) string {
+			return fmt.Sprintf("between `%s` and `%s` (exclusive)", fmtCty(b[0]), fmtCty(b[1]))
+		},
+		check: func(v *big.Float, b []*big.Float) bool { return v.Cmp(b[0]) > 0 && v.Cmp(b[1]) < 0 },
+	}
+)
+
+// GreaterThan is a compare function that checks if the result is greater than the expected value.
+// E.g. use NewIntResults(1) as the expected value to check that the result is greater than 1.
+func GreaterThan(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	return greaterThan.compare(got, expected)
+}
+
+// GreaterThanOrEqual is a compare function that checks if the result is greater than or equal to the expected value.
+func GreaterThanOrEqual(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	return greaterThanOrEqual.compare(got, expected)
+}
+
+// LessThan is a compare function that checks if the result is less than the expected value.
+func LessThan(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	return lessThan.compare(got, expected)
+}
+
+// LessThanOrEqual is a compare function that checks if the result is less than or equal to the expected value.
+func LessThanOrEqual(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	return lessThanOrEqual.compare(got, expected)
+}
+
+// InRange is a compare function that checks if the result is between the two expected values, inclusive of the bounds.
+// E.g. use NewIntResults(7, 365) as the expected values to check that the result is from 7 to 365.
+func InRange(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	return inRange.compare(got, expected)
+}
+
+// InRangeExclusive is a compare function that checks if the result is between the two expected values, exclusive of the bounds.
+func InRangeExclusive(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	return inRangeExclusive.compare(got, expected)
+}
+
+// EachGreaterThan is like GreaterThan, but checks each element of a list.
+func EachGreaterThan(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	return greaterThan.compareEach(got, expected)
+}
+
+// EachGreaterThanOrEqual is like GreaterThanOrEqual, but checks each element of a list.
+func EachGreaterThanOrEqual(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	return greaterThanOrEqual.compareEach(got, expected)
+}
+
+// EachLessThan is like LessThan, but checks each element of a list.
+func EachLessThan(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	return lessThan.compareEach(got, expected)
+}
+
+// EachLessThanOrEqual is like LessThanOrEqual, but checks each element of a list.
+func EachLessThanOrEqual(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	return lessThanOrEqual.compareEach(got, expected)
+}
+
+// EachInRange is like InRange, but checks each element of a list.
+func EachInRange(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	return inRange.compareEach(got, expected)
+}
+
+// EachInRangeExclusive is like InRangeExclusive, but checks each element of a list.
+func EachInRangeExclusive(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	return inRangeExclusive.compareEach(got, expected)
+}
+
+// compare runs the numeric check against a single value.
+func (c numberCheck) compare(got cty.Value, expected []cty.Value) (bool, string, error) {
+	bounds, err := c.parseBounds(expected)
+	if err != nil {
+		return false, "", err
+	}
+	n, ok := asNumber(got)
+	if !ok {
+		return false, fmt.Sprintf("returned value `%s` is not numeric", fmtCty(got)), nil
+	}
+	if !c.check(n, bounds) {
+		return false, fmt.Sprintf("returned value `%s` is not %s", fmtCty(got), c.desc(expected)), nil
+	}
+	return true, "", nil
+}
+
+// compareEach runs the numeric check against each element of a list.
+func (c numberCheck) compareEach(got cty.Value, expected []cty.Value) (bool, string, error) {
+	bounds, err := c.parseBounds(expected)
+	if err != nil {
+		return false, "", err
+	}
+	ok, err := eachElement(got, func(v cty.Value) bool {
+		n, ok := asNumber(v)
+		return ok && c.check(n, bounds)
+	})
+	if err != nil {
+		return collectionFailure(err)
+	}
+	if !ok {
+		return false, fmt.Sprintf("returned values `%s` are not all %s", fmtCty(got), c.desc(expected)), nil
+	}
+	return true, "", nil
+}
+
+// parseBounds checks that the expected values are the right number of known numbers.
+func (c numberCheck) parseBounds(expected []cty.Value) ([]*big.Float, error) {
+	if len(expected) != c.bounds {
+		return nil, fmt.Errorf("expected %d numeric bound(s) but got %d", c.bounds, len(expected))
+	}
+	bounds := make([]*big.Float, len(expected))
+	for i, e := range expected {
+		n, ok := asNumber(e)
+		if !ok {
+			return nil, fmt.Errorf("bound `%s` is not numeric", fmtCty(e))
+		}
+		bounds[i] = n
+	}
+	return bounds, nil
+}
+
+// asNumber converts a known, non-null primitive value to a number.
+// Strings are converted if they contain a valid number, e.g. "1.2".
+func asNumber(got cty.Value) (*big.Float, bool) {
+	if !got.IsKnown() || got.IsNull() || !got.Type().IsPrimitiveType() {
+		return nil, false
+	}
+	cnv, err := convert.Convert(got, cty.Number)
+	if err != nil {
+		return nil, false
+	}
+	return cnv.AsBigFloat(), true
+}
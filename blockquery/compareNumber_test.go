@@ -0,0 +1,148 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestNumberCompareFuncs(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		cmpFn     ResultCompareFunc
+		got       cty.Value
+		expected  []cty.Value
+		ok        bool
+		msg       string
+		expectErr bool
+	}{
+		{
+			desc:     "greater than",
+			cmpFn:    GreaterThan,
+			got:      cty.NumberIntVal(2),
+			expected: NewIntResults(1),
+			ok:       true,
+		},
+		{
+			desc:     "greater than equal bound",
+			cmpFn:    GreaterThan,
+			got:      cty.NumberIntVal(1),
+			expected: NewIntResults(1),
+			msg:      "returned value `1` is not greater than `1`",
+		},
+		{
+			desc:     "greater than or equal",
+			cmpFn:    GreaterThanOrEqual,
+			got:      cty.StringVal("1.2"),
+			expected: NewFloatResults(1.2),
+			ok:       true,
+		},
+		{
+			desc:     "greater than or equal fails",
+			cmpFn:    GreaterThanOrEqual,
+			got:      cty.NumberFloatVal(1.1),
+			expected: NewFloatResults(1.2),
+			msg:      "returned value `1.100000` is not greater than or equal to `1.200000`",
+		},
+		{
+			desc:     "less than",
+			cmpFn:    LessThan,
+			got:      cty.NumberIntVal(9),
+			expected: NewIntResults(10),
+			ok:       true,
+		},
+		{
+			desc:     "less than or equal",
+			cmpFn:    LessThanOrEqual,
+			got:      cty.NumberIntVal(11),
+			expected: NewIntResults(10),
+			msg:      "returned value `11` is not less than or equal to `10`",
+		},
+		{
+			desc:     "in range inclusive bound",
+			cmpFn:    InRange,
+			got:      cty.NumberIntVal(365),
+			expected: NewIntResults(7, 365),
+			ok:       true,
+		},
+		{
+			desc:     "in range fails",
+			cmpFn:    InRange,
+			got:      cty.NumberIntVal(400),
+			expected: NewIntResults(7, 365),
+			msg:      "returned value `400` is not between `7` and `365` (inclusive)",
+		},
+		{
+			desc:     "in range exclusive bound",
+			cmpFn:    InRangeExclusive,
+			got:      cty.NumberIntVal(7),
+			expected: NewIntResults(7, 365),
+			msg:      "returned value `7` is not between `7` and `365` (exclusive)",
+		},
+		{
+			desc:     "not numeric",
+			cmpFn:    GreaterThan,
+			got:      cty.StringVal("TLS1_2"),
+			expected: NewIntResults(1),
+			msg:      "returned value `TLS1_2` is not numeric",
+		},
+		{
+			desc:     "null is not numeric",
+			cmpFn:    LessThan,
+			got:      cty.NullVal(cty.Number),
+			expected: NewIntResults(1),
+			msg:      "returned value `null` is not numeric",
+		},
+		{
+			desc:      "missing bound",
+			cmpFn:     InRange,
+			got:       cty.NumberIntVal(1),
+			expected:  NewIntResults(1),
+			expectErr: true,
+		},
+		{
+			desc:      "non numeric bound",
+			cmpFn:     GreaterThan,
+			got:       cty.NumberIntVal(1),
+			expected:  NewStringResults("a"),
+			expectErr: true,
+		},
+		{
+			desc:     "each greater than",
+			cmpFn:    EachGreaterThan,
+			got:      cty.ListVal([]cty.Value{cty.NumberIntVal(2), cty.NumberIntVal(3)}),
+			expected: NewIntResults(1),
+			ok:       true,
+		},
+		{
+			desc:     "each in range fails",
+			cmpFn:    EachInRange,
+			got:      cty.ListVal([]cty.Value{cty.NumberIntVal(2), cty.NumberIntVal(30)}),
+			expected: NewIntResults(1, 10),
+			msg:      "returned values `[2 30]` are not all between `1` and `10` (inclusive)",
+		},
+		{
+			desc:     "each less than or equal",
+			cmpFn:    EachLessThanOrEqual,
+			got:      cty.ListVal([]cty.Value{cty.NumberIntVal(2), cty.NumberIntVal(10)}),
+			expected: NewIntResults(10),
+			ok:       true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ok, msg, err := tC.cmpFn(tC.got, tC.expected...)
+			if tC.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tC.ok, ok)
+			require.Equal(t, tC.msg, msg)
+		})
+	}
+}
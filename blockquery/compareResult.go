@@ -7,7 +7,6 @@ import (
 	"fmt"
 
 	"github.com/zclconf/go-cty/cty"
-	"github.com/zclconf/go-cty/cty/convert"
 )
 
 type ResultCompareFunc func(cty.Value, ...cty.Value) (bool, string, error)
@@ -45,19 +44,16 @@ func IsNull(got cty.Value, _ ...cty.Value) (bool, string, error) {
 }
 
 // EachIsOneOf is a compare function that checks if the result is one of the expected values.
-// This is useful when the result is an array and you want to check if each element is one of the expected values.
+// This is useful when the result is a list, tuple or set and you want to check if each element is one of the expected values.
 func EachIsOneOf(got cty.Value, expected ...cty.Value) (bool, string, error) {
-	if !got.Type().IsListType() {
-		return false, "", fmt.Errorf("expected a list but got %s", got.Type().FriendlyName())
+	ok, err := eachElement(got, func(v cty.Value) bool {
+		return compareResults(v, expected)
+	})
+	if err != nil {
+		return collectionFailure(err)
 	}
-	results := make([]bool, 0, got.LengthInt())
-	it := got.ElementIterator()
-	for it.Next() {
-		_, v := it.Element()
-		results = append(results, compareResults(v, expected))
-	}
-	if !allTrue(results...) {
-		return false, fmt.Sprintf("returned values `%s` not in expected values `%v`", fmtCty(got), fmtCty(cty.ListVal(expected))), nil
+	if !ok {
+		return false, fmt.Sprintf("returned values `%s` not in expected values `%v`", fmtCty(got), fmtCty(cty.TupleVal(expected))), nil
 	}
 	return true, "", nil
 }
@@ -66,29 +62,63 @@ func EachIsOneOf(got cty.Value, expected ...cty.Value) (bool, string, error) {
 func IsOneOf(got cty.Value, expected ...cty.Value) (bool, string, error) {
 	ok := compareResults(got, expected)
 	if !ok {
-		return false, fmt.Sprintf("returned value `%s` not in expected values `%v`", fmtCty(got), fmtCty(cty.ListVal(expected))), nil
+		return false, fmt.Sprintf("returned value `%s` not in expected values `%v`", fmtCty(got), fmtCty(cty.TupleVal(expected))), nil
 	}
 	return ok, "", nil
 }
 
-// compareResults compares the result with the expected values.
+// MatchFailure is a query match that did not satisfy a compare function.
+type MatchFailure struct {
+	Match
+	Message string // The failure message, prefixed with the path of the offending value.
+}
+
+// CompareEachMatch runs the compare function against the value of each match, e.g. those returned by QueryCtyMatches.
+// It returns the matches that failed, so that the path of each offending value can be reported.
+func CompareEachMatch(matches []Match, cmpFn ResultCompareFunc, expected ...cty.Value) ([]MatchFailure, error) {
+	var failures []MatchFailure
+	for _, m := range matches {
+		ok, msg, err := cmpFn(m.Value, expected...)
+		if err != nil {
+			return nil, fmt.Errorf("could not compare value at `%s`: %w", FormatPath(m.Path), err)
+		}
+		if ok {
+			continue
+		}
+		if len(m.Path) > 0 {
+			msg = fmt.Sprintf("`%s`: %s", FormatPath(m.Path), msg)
+		}
+		failures = append(failures, MatchFailure{Match: m, Message: msg})
+	}
+	return failures, nil
+}
+
+// compareResults checks if the result equals any of the expected values, see valuesEqual for how types are normalised.
 func compareResults(got cty.Value, want []cty.Value) bool {
 	if !got.IsKnown() || got.IsNull() {
 		return false
 	}
 	for _, w := range want {
-		cnv, err := convert.Convert(got, w.Type())
-		if err != nil {
-			continue
-		}
-		ok := w.Equals(cnv).True()
-		if ok {
+		if valuesEqual(got, w) {
 			return true
 		}
 	}
 	return false
 }
 
+// eachElement runs the check against each element of a list, tuple or set and reports whether all of them passed.
+func eachElement(got cty.Value, check func(cty.Value) bool) (bool, error) {
+	elems, err := collectionElements(got)
+	if err != nil {
+		return false, err
+	}
+	results := make([]bool, 0, len(elems))
+	for _, v := range elems {
+		results = append(results, check(v))
+	}
+	return allTrue(results...), nil
+}
+
 // allTrue checks if all the values are true.
 func allTrue(in ...bool) bool {
 	for _, b := range in {
@@ -101,6 +131,12 @@ func allTrue(in ...bool) bool {
 
 // fmtCty formats the cty value to a string.
 func fmtCty(in cty.Value) string {
+	if !in.IsKnown() {
+		return "(unknown)"
+	}
+	if in.IsNull() {
+		return "null"
+	}
 	switch in.Type() {
 	case cty.Bool:
 		return fmt.Sprintf("%t", in.True())
@@ -115,7 +151,7 @@ func fmtCty(in cty.Value) string {
 	case cty.String:
 		return in.AsString()
 	}
-	if in.Type().IsListType() || in.Type().IsTupleType() {
+	if in.Type().IsListType() || in.Type().IsTupleType() || in.Type().IsSetType() {
 		res := make([]string, 0, in.LengthInt())
 		it := in.ElementIterator()
 		for it.Next() {
@@ -0,0 +1,144 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// MatchesShape is a compare function that checks if the result matches one of the expected partial values.
+// Every attribute in the expected value must be present in the result with a matching value, at any depth.
+// Attributes of the result that are not in the expected value are ignored.
+// Lists and tuples must have the same length and match element by element, sets match in any order.
+// A null attribute in the expected value matches an attribute that is missing or null.
+// E.g. use NewShapeResults(`{"sku": {"name": "Standard"}}`) to check the sku name without caring about the tier.
+func MatchesShape(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	reasons := make([]string, 0, len(expected))
+	for _, want := range expected {
+		reason := shapeMismatch(got, want, cty.Path{})
+		if reason == "" {
+			return true, "", nil
+		}
+		reasons = append(reasons, reason)
+	}
+	if len(reasons) == 1 {
+		return false, fmt.Sprintf("returned value does not match the expected shape: %s", reasons[0]), nil
+	}
+	return false, fmt.Sprintf("returned value `%s` does not match any of the expected shapes", fmtCty(got)), nil
+}
+
+// ShapeFromJSON converts a JSON document into a value for use with MatchesShape.
+func ShapeFromJSON(src string) (cty.Value, error) {
+	ty, err := ctyjson.ImpliedType([]byte(src))
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("could not parse shape: %w", err)
+	}
+	val, err := ctyjson.Unmarshal([]byte(src), ty)
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("could not parse shape: %w", err)
+	}
+	return val, nil
+}
+
+// shapeMismatch returns a description of the first difference between the result and the expected shape,
+// or an empty string if the result matches.
+func shapeMismatch(got, want cty.Value, path cty.Path) string {
+	if want.IsNull() {
+		if got.IsKnown() && got.IsNull() {
+			return ""
+		}
+		return fmt.Sprintf("%s is `%s`, expected null", fmtShapePath(path), fmtCty(got))
+	}
+	if !want.IsKnown() {
+		return fmt.Sprintf("%s has an unknown expected value", fmtShapePath(path))
+	}
+	if !got.IsKnown() {
+		return fmt.Sprintf("%s is unknown", fmtShapePath(path))
+	}
+	if got.IsNull() {
+		return fmt.Sprintf("%s is missing", fmtShapePath(path))
+	}
+	gotTy, wantTy := got.Type(), want.Type()
+	switch {
+	case isMappingType(wantTy):
+		if !isMappingType(gotTy) {
+			return fmt.Sprintf("%s is %s, expected an object", fmtShapePath(path), gotTy.FriendlyName())
+		}
+		return mappingShapeMismatch(got, want, path)
+	case isCollectionType(wantTy):
+		if !isCollectionType(gotTy) {
+			return fmt.Sprintf("%s is %s, expected a list", fmtShapePath(path), gotTy.FriendlyName())
+		}
+		gotElems, _ := collectionElements(got)
+		wantElems, _ := collectionElements(want)
+		if len(gotElems) != len(wantElems) {
+			return fmt.Sprintf("%s has length %d, expected %d", fmtShapePath(path), len(gotElems), len(wantElems))
+		}
+		if gotTy.IsSetType() || wantTy.IsSetType() {
+			return unorderedShapeMismatch(gotElems, wantElems, path)
+		}
+		for i := range wantElems {
+			if reason := shapeMismatch(gotElems[i], wantElems[i], path.IndexInt(i)); reason != "" {
+				return reason
+			}
+		}
+		return ""
+	}
+	if !valuesEqual(got, want) {
+		return fmt.Sprintf("%s is `%s`, expected `%s`", fmtShapePath(path), fmtCty(got), fmtCty(want))
+	}
+	return ""
+}
+
+// mappingShapeMismatch checks each attribute of the expected shape against the map or object result.
+// Attributes are checked in lexical order so that the reported difference is stable.
+func mappingShapeMismatch(got, want cty.Value, path cty.Path) string {
+	gotMap := got.AsValueMap()
+	it := want.ElementIterator()
+	for it.Next() {
+		k, w := it.Element()
+		name := k.AsString()
+		g, ok := gotMap[name]
+		if !ok {
+			if w.IsNull() {
+				continue
+			}
+			return fmt.Sprintf("%s is missing", fmtShapePath(path.GetAttr(name)))
+		}
+		if reason := shapeMismatch(g, w, path.GetAttr(name)); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// unorderedShapeMismatch checks that each expected element matches a distinct element of the result.
+func unorderedShapeMismatch(got, want []cty.Value, path cty.Path) string {
+	used := make([]bool, len(got))
+	for _, w := range want {
+		found := false
+		for i, g := range got {
+			if !used[i] && shapeMismatch(g, w, path) == "" {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("%s has no element matching `%s`", fmtShapePath(path), fmtCty(w))
+		}
+	}
+	return ""
+}
+
+// fmtShapePath formats the path for a shape mismatch message.
+func fmtShapePath(path cty.Path) string {
+	if len(path) == 0 {
+		return "value"
+	}
+	return fmt.Sprintf("`%s`", FormatPath(path))
+}
@@ -0,0 +1,122 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestMatchesShape(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		got      cty.Value
+		expected []cty.Value
+		ok       bool
+		msg      string
+	}{
+		{
+			desc:     "partial nested object",
+			got:      evalHCL(t, `{ sku = { name = "Standard", tier = "Regional" }, location = "uksouth" }`),
+			expected: NewShapeResults(`{"sku": {"name": "Standard"}}`),
+			ok:       true,
+		},
+		{
+			desc: "cty object pattern",
+			got:  evalHCL(t, `{ sku = { name = "Standard", tier = "Regional" } }`),
+			expected: []cty.Value{cty.ObjectVal(map[string]cty.Value{
+				"sku": cty.ObjectVal(map[string]cty.Value{"tier": cty.StringVal("Regional")}),
+			})},
+			ok: true,
+		},
+		{
+			desc:     "nested value differs",
+			got:      evalHCL(t, `{ sku = { name = "Premium", tier = "Regional" } }`),
+			expected: NewShapeResults(`{"sku": {"name": "Standard"}}`),
+			msg:      "returned value does not match the expected shape: `sku.name` is `Premium`, expected `Standard`",
+		},
+		{
+			desc:     "attribute missing",
+			got:      evalHCL(t, `{ sku = { tier = "Regional" } }`),
+			expected: NewShapeResults(`{"sku": {"name": "Standard"}}`),
+			msg:      "returned value does not match the expected shape: `sku.name` is missing",
+		},
+		{
+			desc:     "null pattern matches missing attribute",
+			got:      evalHCL(t, `{ sku = { tier = "Regional" } }`),
+			expected: NewShapeResults(`{"sku": {"name": null}}`),
+			ok:       true,
+		},
+		{
+			desc:     "not an object",
+			got:      evalHCL(t, `{ sku = "Standard" }`),
+			expected: NewShapeResults(`{"sku": {"name": "Standard"}}`),
+			msg:      "returned value does not match the expected shape: `sku` is string, expected an object",
+		},
+		{
+			desc:     "number converted",
+			got:      evalHCL(t, `{ properties = { retention = { days = "30", enabled = true } } }`),
+			expected: NewShapeResults(`{"properties": {"retention": {"days": 30}}}`),
+			ok:       true,
+		},
+		{
+			desc:     "list elements match partially",
+			got:      evalHCL(t, `{ rules = [{ name = "a", action = "Deny" }, { name = "b", action = "Allow" }] }`),
+			expected: NewShapeResults(`{"rules": [{"action": "Deny"}, {"action": "Allow"}]}`),
+			ok:       true,
+		},
+		{
+			desc:     "list element differs",
+			got:      evalHCL(t, `{ rules = [{ name = "a", action = "Deny" }, { name = "b", action = "Allow" }] }`),
+			expected: NewShapeResults(`{"rules": [{"action": "Deny"}, {"action": "Deny"}]}`),
+			msg:      "returned value does not match the expected shape: `rules[1].action` is `Allow`, expected `Deny`",
+		},
+		{
+			desc:     "list length differs",
+			got:      evalHCL(t, `{ zones = ["1", "2"] }`),
+			expected: NewShapeResults(`{"zones": ["1", "2", "3"]}`),
+			msg:      "returned value does not match the expected shape: `zones` has length 2, expected 3",
+		},
+		{
+			desc:     "set matches in any order",
+			got:      cty.ObjectVal(map[string]cty.Value{"zones": cty.SetVal(NewStringResults("1", "2"))}),
+			expected: NewShapeResults(`{"zones": ["2", "1"]}`),
+			ok:       true,
+		},
+		{
+			desc:     "one of several shapes",
+			got:      evalHCL(t, `{ sku = { name = "Premium" } }`),
+			expected: NewShapeResults(`{"sku": {"name": "Standard"}}`, `{"sku": {"name": "Premium"}}`),
+			ok:       true,
+		},
+		{
+			desc:     "none of several shapes",
+			got:      evalHCL(t, `{ sku = "Basic" }`),
+			expected: NewShapeResults(`{"sku": {"name": "Standard"}}`, `{"sku": {"name": "Premium"}}`),
+			msg:      "returned value `cty.ObjectVal(map[string]cty.Value{\"sku\":cty.StringVal(\"Basic\")})` does not match any of the expected shapes",
+		},
+		{
+			desc:     "unknown",
+			got:      cty.UnknownVal(cty.DynamicPseudoType),
+			expected: NewShapeResults(`{"sku": {"name": "Standard"}}`),
+			msg:      "returned value does not match the expected shape: value is unknown",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ok, msg, err := MatchesShape(tC.got, tC.expected...)
+			require.NoError(t, err)
+			require.Equal(t, tC.ok, ok)
+			require.Equal(t, tC.msg, msg)
+		})
+	}
+}
+
+func TestShapeFromJSON(t *testing.T) {
+	_, err := ShapeFromJSON(`{"sku": `)
+	require.Error(t, err)
+	require.Panics(t, func() { NewShapeResults(`not json`) })
+}
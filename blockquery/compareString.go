@@ -0,0 +1,166 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/match"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// MatchesRegex returns a compare function that checks if the result matches any of the regular expressions.
+// The patterns are compiled when this function is called, so that an invalid pattern is caught when the ruleset is built.
+// It panics if a pattern does not compile. Use (?i) in the pattern for a case-insensitive match.
+// Any expected values passed to the returned function are ignored.
+func MatchesRegex(patterns ...string) ResultCompareFunc {
+	res := mustCompileRegexes(patterns)
+	return func(got cty.Value, _ ...cty.Value) (bool, string, error) {
+		if !matchesAny(got, res) {
+			return false, fmt.Sprintf("returned value `%s` does not match %s", fmtCty(got), fmtPatterns(patterns)), nil
+		}
+		return true, "", nil
+	}
+}
+
+// EachMatchesRegex is like MatchesRegex, but checks that each element of a list matches.
+func EachMatchesRegex(patterns ...string) ResultCompareFunc {
+	res := mustCompileRegexes(patterns)
+	return func(got cty.Value, _ ...cty.Value) (bool, string, error) {
+		ok, err := eachElement(got, func(v cty.Value) bool {
+			return matchesAny(v, res)
+		})
+		if err != nil {
+			return collectionFailure(err)
+		}
+		if !ok {
+			return false, fmt.Sprintf("returned values `%s` do not all match %s", fmtCty(got), fmtPatterns(patterns)), nil
+		}
+		return true, "", nil
+	}
+}
+
+// MatchesGlob returns a compare function that checks if the result matches any of the glob patterns.
+// A `*` in a pattern matches any sequence of characters, including `/`, and a `?` matches a single character.
+// Any expected values passed to the returned function are ignored.
+func MatchesGlob(patterns ...string) ResultCompareFunc {
+	return func(got cty.Value, _ ...cty.Value) (bool, string, error) {
+		if !matchesAnyGlob(got, patterns) {
+			return false, fmt.Sprintf("returned value `%s` does not match %s", fmtCty(got), fmtPatterns(patterns)), nil
+		}
+		return true, "", nil
+	}
+}
+
+// EachMatchesGlob is like MatchesGlob, but checks that each element of a list matches.
+func EachMatchesGlob(patterns ...string) ResultCompareFunc {
+	return func(got cty.Value, _ ...cty.Value) (bool, string, error) {
+		ok, err := eachElement(got, func(v cty.Value) bool {
+			return matchesAnyGlob(v, patterns)
+		})
+		if err != nil {
+			return collectionFailure(err)
+		}
+		if !ok {
+			return false, fmt.Sprintf("returned values `%s` do not all match %s", fmtCty(got), fmtPatterns(patterns)), nil
+		}
+		return true, "", nil
+	}
+}
+
+// IsOneOfIgnoreCase is like IsOneOf, but strings are compared case-insensitively.
+func IsOneOfIgnoreCase(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	if !compareResultsIgnoreCase(got, expected) {
+		return false, fmt.Sprintf("returned value `%s` not in expected values `%v` (case-insensitive)", fmtCty(got), fmtCty(cty.TupleVal(expected))), nil
+	}
+	return true, "", nil
+}
+
+// EachIsOneOfIgnoreCase is like EachIsOneOf, but strings are compared case-insensitively.
+func EachIsOneOfIgnoreCase(got cty.Value, expected ...cty.Value) (bool, string, error) {
+	ok, err := eachElement(got, func(v cty.Value) bool {
+		return compareResultsIgnoreCase(v, expected)
+	})
+	if err != nil {
+		return collectionFailure(err)
+	}
+	if !ok {
+		return false, fmt.Sprintf("returned values `%s` not in expected values `%v` (case-insensitive)", fmtCty(got), fmtCty(cty.TupleVal(expected))), nil
+	}
+	return true, "", nil
+}
+
+// compareResultsIgnoreCase compares the result with the expected values, comparing strings case-insensitively.
+func compareResultsIgnoreCase(got cty.Value, want []cty.Value) bool {
+	s, ok := asString(got)
+	if !ok {
+		return compareResults(got, want)
+	}
+	for _, w := range want {
+		if w.Type() == cty.String && w.IsKnown() && !w.IsNull() && strings.EqualFold(s, w.AsString()) {
+			return true
+		}
+	}
+	return compareResults(got, want)
+}
+
+// mustCompileRegexes compiles the patterns, panicking if any do not compile.
+func mustCompileRegexes(patterns []string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		res[i] = regexp.MustCompile(p)
+	}
+	return res
+}
+
+// matchesAny checks if the value is a string that matches any of the regular expressions.
+func matchesAny(got cty.Value, res []*regexp.Regexp) bool {
+	s, ok := asString(got)
+	if !ok {
+		return false
+	}
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAnyGlob checks if the value is a string that matches any of the glob patterns.
+func matchesAnyGlob(got cty.Value, patterns []string) bool {
+	s, ok := asString(got)
+	if !ok {
+		return false
+	}
+	for _, p := range patterns {
+		if match.Match(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// asString converts a known, non-null primitive value to a string.
+func asString(got cty.Value) (string, bool) {
+	if !got.IsKnown() || got.IsNull() || !got.Type().IsPrimitiveType() {
+		return "", false
+	}
+	cnv, err := convert.Convert(got, cty.String)
+	if err != nil {
+		return "", false
+	}
+	return cnv.AsString(), true
+}
+
+// fmtPatterns formats the patterns for use in failure messages.
+func fmtPatterns(patterns []string) string {
+	if len(patterns) == 1 {
+		return fmt.Sprintf("pattern `%s`", patterns[0])
+	}
+	return fmt.Sprintf("any of patterns `%v`", patterns)
+}
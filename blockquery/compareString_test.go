@@ -0,0 +1,135 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestStringCompareFuncs(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		cmpFn     ResultCompareFunc
+		got       cty.Value
+		expected  []cty.Value
+		ok        bool
+		msg       string
+		expectErr bool
+	}{
+		{
+			desc:  "regex matches",
+			cmpFn: MatchesRegex(`^st[a-z0-9]{3,22}$`),
+			got:   cty.StringVal("stprod001"),
+			ok:    true,
+		},
+		{
+			desc:  "regex does not match",
+			cmpFn: MatchesRegex(`^st[a-z0-9]{3,22}$`),
+			got:   cty.StringVal("Storage-Prod"),
+			msg:   "returned value `Storage-Prod` does not match pattern `^st[a-z0-9]{3,22}$`",
+		},
+		{
+			desc:  "regex any of several",
+			cmpFn: MatchesRegex(`^Standard_`, `^Premium_`),
+			got:   cty.StringVal("Premium_LRS"),
+			ok:    true,
+		},
+		{
+			desc:  "regex number is converted",
+			cmpFn: MatchesRegex(`^\d+$`),
+			got:   cty.NumberIntVal(42),
+			ok:    true,
+		},
+		{
+			desc:  "regex null does not match",
+			cmpFn: MatchesRegex(`.*`),
+			got:   cty.NullVal(cty.String),
+			msg:   "returned value `null` does not match pattern `.*`",
+		},
+		{
+			desc:  "each regex",
+			cmpFn: EachMatchesRegex(`(?i)^standard_`),
+			got:   cty.ListVal([]cty.Value{cty.StringVal("Standard_LRS"), cty.StringVal("standard_ZRS")}),
+			ok:    true,
+		},
+		{
+			desc:  "each regex fails",
+			cmpFn: EachMatchesRegex(`^Standard_`, `^Premium_`),
+			got:   cty.ListVal([]cty.Value{cty.StringVal("Standard_LRS"), cty.StringVal("Basic")}),
+			msg:   "returned values `[Standard_LRS Basic]` do not all match any of patterns `[^Standard_ ^Premium_]`",
+		},
+		{
+			desc:      "each regex not a list",
+			cmpFn:     EachMatchesRegex(`.*`),
+			got:       cty.StringVal("a"),
+			expectErr: true,
+		},
+		{
+			desc:  "glob matches across slashes",
+			cmpFn: MatchesGlob("/subscriptions/*/resourceGroups/rg-prod-*"),
+			got:   cty.StringVal("/subscriptions/0000/resourceGroups/rg-prod-001"),
+			ok:    true,
+		},
+		{
+			desc:  "glob does not match",
+			cmpFn: MatchesGlob("Standard_?RS"),
+			got:   cty.StringVal("Standard_GZRS"),
+			msg:   "returned value `Standard_GZRS` does not match pattern `Standard_?RS`",
+		},
+		{
+			desc:  "each glob",
+			cmpFn: EachMatchesGlob("Standard_?RS"),
+			got:   cty.ListVal([]cty.Value{cty.StringVal("Standard_LRS"), cty.StringVal("Standard_ZRS")}),
+			ok:    true,
+		},
+		{
+			desc:     "is one of ignore case",
+			cmpFn:    IsOneOfIgnoreCase,
+			got:      cty.StringVal("standard"),
+			expected: NewStringResults("Standard", "Premium"),
+			ok:       true,
+		},
+		{
+			desc:     "is one of ignore case fails",
+			cmpFn:    IsOneOfIgnoreCase,
+			got:      cty.StringVal("basic"),
+			expected: NewStringResults("Standard", "Premium"),
+			msg:      "returned value `basic` not in expected values `[Standard Premium]` (case-insensitive)",
+		},
+		{
+			desc:     "is one of ignore case non string",
+			cmpFn:    IsOneOfIgnoreCase,
+			got:      cty.NumberIntVal(1),
+			expected: NewIntResults(1),
+			ok:       true,
+		},
+		{
+			desc:     "each is one of ignore case",
+			cmpFn:    EachIsOneOfIgnoreCase,
+			got:      cty.ListVal([]cty.Value{cty.StringVal("ENABLED"), cty.StringVal("disabled")}),
+			expected: NewStringResults("Enabled", "Disabled"),
+			ok:       true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ok, msg, err := tC.cmpFn(tC.got, tC.expected...)
+			if tC.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tC.ok, ok)
+			require.Equal(t, tC.msg, msg)
+		})
+	}
+}
+
+func TestMatchesRegexInvalidPattern(t *testing.T) {
+	require.Panics(t, func() { MatchesRegex(`[`) })
+	require.Panics(t, func() { EachMatchesRegex(`^ok$`, `(`) })
+}
@@ -0,0 +1,151 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QuerySyntaxError is returned by Compile when a query is malformed.
+type QuerySyntaxError struct {
+	Query   string // The query that could not be compiled.
+	Column  int    // The column of the error in the query, starting at 1.
+	Message string // A description of the error.
+}
+
+func (e *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("invalid query `%s` at column %d: %s", e.Query, e.Column, e.Message)
+}
+
+// segmentKind is the type of operation a query segment performs.
+type segmentKind int
+
+const (
+	segmentAttribute    segmentKind = iota // An object attribute or map key, e.g. `name`.
+	segmentIndex                           // A list index, e.g. `0`.
+	segmentListWildcard                    // All elements of a list, `#`.
+	segmentKeyWildcard                     // All values of an object or map, `*`.
+	segmentFilter                          // Elements matching a filter, e.g. `#(name=="foo")`.
+	segmentDescent                         // An attribute at any depth, e.g. `..name`.
+)
+
+// querySegment is a single node of a compiled query.
+type querySegment struct {
+	kind   segmentKind
+	text   string       // The segment as written in the query.
+	rest   string       // The query from this segment onwards, used in error messages.
+	name   string       // The attribute name for attribute and descent segments.
+	index  int          // The list index for index segments.
+	filter *queryFilter // The filter for filter segments.
+}
+
+// CompiledQuery is a query that has been parsed and validated.
+// It is safe to evaluate a compiled query concurrently against many values.
+type CompiledQuery struct {
+	query    string
+	segments []querySegment
+}
+
+// Compile parses a query into a CompiledQuery.
+// Syntax errors are returned as a *QuerySyntaxError with the column of the error.
+// An empty query is valid and evaluates to the value itself.
+func Compile(query string) (*CompiledQuery, error) {
+	segments, serr := parseQuery(query, 0)
+	if serr != nil {
+		return nil, &QuerySyntaxError{Query: query, Column: serr.offset + 1, Message: serr.msg}
+	}
+	return &CompiledQuery{query: query, segments: segments}, nil
+}
+
+// MustCompile is like Compile but panics if the query cannot be compiled.
+// It is intended for use when the ruleset is built, so that a malformed query is caught before it is run.
+func MustCompile(query string) *CompiledQuery {
+	q, err := Compile(query)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// String returns the source text of the query.
+func (q *CompiledQuery) String() string {
+	return q.query
+}
+
+// syntaxError is the internal representation of a syntax error, with the offset relative to the full query.
+type syntaxError struct {
+	offset int
+	msg    string
+}
+
+// parseQuery parses the query into segments.
+// The base is the offset of the query within the outermost query, used for error positions in filters.
+func parseQuery(query string, base int) ([]querySegment, *syntaxError) {
+	segments := make([]querySegment, 0, strings.Count(query, ".")+1)
+	pos := 0
+	for pos < len(query) {
+		rest := query[pos:]
+		if strings.HasPrefix(rest, "..") {
+			name, _ := nextQuerySegment(rest[2:])
+			if !isPlainSegment(name) {
+				return nil, &syntaxError{offset: base + pos, msg: "recursive descent must be followed by an attribute name"}
+			}
+			segments = append(segments, querySegment{kind: segmentDescent, text: ".." + name, rest: rest, name: name})
+			pos += 2 + len(name)
+		} else {
+			segment, _ := nextQuerySegment(rest)
+			seg, serr := parseQuerySegment(segment, base+pos)
+			if serr != nil {
+				return nil, serr
+			}
+			seg.text = segment
+			seg.rest = rest
+			segments = append(segments, seg)
+			pos += len(segment)
+		}
+		// Skip the dot separating this segment from the next, a double dot is the start of the next segment.
+		if pos < len(query) && !strings.HasPrefix(query[pos:], "..") {
+			pos++
+			if pos == len(query) {
+				return nil, &syntaxError{offset: base + pos - 1, msg: "query must not end with a dot"}
+			}
+		}
+	}
+	return segments, nil
+}
+
+// parseQuerySegment parses a single segment of a query.
+func parseQuerySegment(segment string, offset int) (querySegment, *syntaxError) {
+	switch {
+	case segment == "":
+		return querySegment{}, &syntaxError{offset: offset, msg: "empty query segment"}
+	case segment == "*":
+		return querySegment{kind: segmentKeyWildcard}, nil
+	case querySegmentIsFilter(segment):
+		f, serr := parseQueryFilter(segment, offset)
+		if serr != nil {
+			return querySegment{}, serr
+		}
+		return querySegment{kind: segmentFilter, filter: f}, nil
+	}
+	if i, isList := querySegmentPertainsToList(segment); isList {
+		switch {
+		case segment == "#":
+			return querySegment{kind: segmentListWildcard}, nil
+		case i < 0:
+			return querySegment{}, &syntaxError{offset: offset, msg: fmt.Sprintf("list index %s must not be negative", segment)}
+		}
+		return querySegment{kind: segmentIndex, index: i}, nil
+	}
+	if !isPlainSegment(segment) {
+		return querySegment{}, &syntaxError{offset: offset, msg: fmt.Sprintf("unexpected character in query segment %s", segment)}
+	}
+	return querySegment{kind: segmentAttribute, name: segment}, nil
+}
+
+// isPlainSegment checks that a segment is an attribute name without any query syntax.
+func isPlainSegment(segment string) bool {
+	return segment != "" && segment != "*" && !strings.ContainsAny(segment, `#()"`)
+}
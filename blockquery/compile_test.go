@@ -0,0 +1,122 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestCompile(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		query   string
+		column  int
+		message string
+	}{
+		{
+			desc:  "simple",
+			query: "properties.sku.name",
+		},
+		{
+			desc:  "wildcards, filters and descent",
+			query: `properties.subnets.#(name=="a.b")#.properties..privateEndpointNetworkPolicies`,
+		},
+		{
+			desc:  "empty",
+			query: "",
+		},
+		{
+			desc:    "empty segment",
+			query:   "properties..",
+			column:  11,
+			message: "recursive descent must be followed by an attribute name",
+		},
+		{
+			desc:    "leading dot",
+			query:   ".properties",
+			column:  1,
+			message: "empty query segment",
+		},
+		{
+			desc:    "trailing dot",
+			query:   "properties.",
+			column:  11,
+			message: "query must not end with a dot",
+		},
+		{
+			desc:    "negative index",
+			query:   "properties.-1",
+			column:  12,
+			message: "list index -1 must not be negative",
+		},
+		{
+			desc:    "unterminated filter",
+			query:   `subnets.#(name=="a"`,
+			column:  20,
+			message: "filter is not terminated with `)`",
+		},
+		{
+			desc:    "unquoted filter value",
+			query:   `subnets.#(name == a)`,
+			column:  19,
+			message: "invalid value a, strings must be double-quoted",
+		},
+		{
+			desc:    "invalid filter path",
+			query:   `subnets.#(properties..==1)`,
+			column:  21,
+			message: "recursive descent must be followed by an attribute name",
+		},
+		{
+			desc:    "pattern with number",
+			query:   `subnets.#(name%1)`,
+			column:  16,
+			message: "pattern operator % requires a string value",
+		},
+		{
+			desc:    "stray parenthesis",
+			query:   `properties.na(me`,
+			column:  12,
+			message: "unexpected character in query segment na(me",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			q, err := Compile(tC.query)
+			if tC.message == "" {
+				require.NoError(t, err)
+				require.Equal(t, tC.query, q.String())
+				return
+			}
+			var syntaxErr *QuerySyntaxError
+			require.ErrorAs(t, err, &syntaxErr)
+			require.Equal(t, tC.column, syntaxErr.Column)
+			require.Equal(t, tC.message, syntaxErr.Message)
+		})
+	}
+}
+
+func TestMustCompilePanics(t *testing.T) {
+	require.Panics(t, func() { MustCompile("a.") })
+	require.NotPanics(t, func() { MustCompile("a.b") })
+}
+
+func TestCompiledQueryEval(t *testing.T) {
+	q := MustCompile("sku.name")
+	for _, name := range []string{"Basic", "Standard"} {
+		in := cty.ObjectVal(map[string]cty.Value{
+			"sku": cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal(name)}),
+		})
+		out, err := q.Eval(in)
+		require.NoError(t, err)
+		require.Equal(t, cty.StringVal(name), out)
+	}
+	in := cty.ObjectVal(map[string]cty.Value{"key": cty.StringVal("value")})
+	out, err := MustCompile("").Eval(in)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+}
@@ -0,0 +1,242 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/match"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// filterOperators are the supported filter operators.
+// Longer operators must come before their prefixes so that e.g. `<=` is not parsed as `<`.
+var filterOperators = []string{"==", "!=", "<=", ">=", "!%", "<", ">", "%"}
+
+// queryFilter is a parsed filter segment, e.g. `#(name=="foo")` or `#(name=="foo")#`.
+type queryFilter struct {
+	path  *CompiledQuery // The query to run against each element, an empty query for the element itself.
+	op    string         // The comparison operator, empty when the filter only checks that the path exists.
+	value cty.Value      // The literal value to compare against.
+	all   bool           // Return all matches (trailing `#`) rather than the first match.
+}
+
+// querySegmentIsFilter checks if a query segment is a filter, e.g. `#(name=="foo")`.
+func querySegmentIsFilter(segment string) bool {
+	return strings.HasPrefix(segment, "#(")
+}
+
+// parseQueryFilter parses a filter segment in the form `#(path op value)` or `#(path op value)#`.
+// The offset is the position of the segment in the query, used for error positions.
+func parseQueryFilter(segment string, offset int) (*queryFilter, *syntaxError) {
+	f := &queryFilter{}
+	body := strings.TrimPrefix(segment, "#(")
+	if strings.HasSuffix(body, ")#") {
+		f.all = true
+		body = strings.TrimSuffix(body, "#")
+	}
+	if !strings.HasSuffix(body, ")") {
+		return nil, &syntaxError{offset: offset + len(segment), msg: "filter is not terminated with `)`"}
+	}
+	body = strings.TrimSuffix(body, ")")
+	bodyOffset := offset + 2
+	i, op := findFilterOperator(body)
+	pathEnd := len(body)
+	if i != -1 {
+		pathEnd = i
+	}
+	path := strings.TrimSpace(body[:pathEnd])
+	if i == -1 && path == "" {
+		return nil, &syntaxError{offset: bodyOffset, msg: "filter is empty"}
+	}
+	pathOffset := bodyOffset + strings.Index(body, path)
+	segments, serr := parseQuery(path, pathOffset)
+	if serr != nil {
+		return nil, serr
+	}
+	f.path = &CompiledQuery{query: path, segments: segments}
+	if i == -1 {
+		return f, nil
+	}
+	f.op = op
+	raw := body[i+len(op):]
+	valueOffset := bodyOffset + i + len(op) + len(raw) - len(strings.TrimLeft(raw, " "))
+	val, err := parseFilterValue(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, &syntaxError{offset: valueOffset, msg: err.Error()}
+	}
+	if (op == "%" || op == "!%") && val.Type() != cty.String {
+		return nil, &syntaxError{offset: valueOffset, msg: fmt.Sprintf("pattern operator %s requires a string value", op)}
+	}
+	f.value = val
+	return f, nil
+}
+
+// findFilterOperator returns the position and value of the first operator in the filter body
+// that is not inside a quoted string or a nested filter, e.g. `tags.#(key=="env")`.
+func findFilterOperator(body string) (int, string) {
+	depth := 0
+	inQuote := false
+	for i := 0; i < len(body); i++ {
+		switch {
+		case inQuote && body[i] == '\\':
+			i++
+		case body[i] == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case body[i] == '(':
+			depth++
+		case body[i] == ')':
+			depth--
+		case depth == 0:
+			for _, op := range filterOperators {
+				if strings.HasPrefix(body[i:], op) {
+					return i, op
+				}
+			}
+		}
+	}
+	return -1, ""
+}
+
+// parseFilterValue parses the literal on the right hand side of a filter.
+// Supported literals are double-quoted strings, numbers, `true`, `false` and `null`.
+func parseFilterValue(s string) (cty.Value, error) {
+	switch s {
+	case "":
+		return cty.NilVal, fmt.Errorf("missing value")
+	case "true":
+		return cty.True, nil
+	case "false":
+		return cty.False, nil
+	case "null":
+		return cty.NullVal(cty.DynamicPseudoType), nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		str, err := strconv.Unquote(s)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("invalid string value %s", s)
+		}
+		return cty.StringVal(str), nil
+	}
+	n, err := cty.ParseNumberVal(s)
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("invalid value %s, strings must be double-quoted", s)
+	}
+	return n, nil
+}
+
+// matches checks if the element satisfies the filter.
+func (f *queryFilter) matches(elem cty.Value) bool {
+	got, err := f.path.Eval(elem)
+	if err != nil {
+		return false
+	}
+	if !got.IsKnown() {
+		return false
+	}
+	if f.op == "" {
+		return !got.IsNull() && !(f.path.endsWithAllFilter() && got.LengthInt() == 0)
+	}
+	if f.value.IsNull() {
+		switch f.op {
+		case "==":
+			return got.IsNull()
+		case "!=":
+			return !got.IsNull()
+		}
+		return false
+	}
+	if got.IsNull() {
+		return f.op == "!="
+	}
+	cnv, err := convert.Convert(got, f.value.Type())
+	if err != nil {
+		return f.op == "!=" || f.op == "!%"
+	}
+	switch f.op {
+	case "==":
+		return cnv.Equals(f.value).True()
+	case "!=":
+		return !cnv.Equals(f.value).True()
+	case "%":
+		return match.Match(cnv.AsString(), f.value.AsString())
+	case "!%":
+		return !match.Match(cnv.AsString(), f.value.AsString())
+	}
+	return compareOrdered(cnv, f.value, f.op)
+}
+
+// endsWithAllFilter checks if the last segment of the query is a filter returning all matches, e.g. `tags.#(key=="env")#`.
+// Such a query returns an empty list rather than nothing when no element matches.
+func (q *CompiledQuery) endsWithAllFilter() bool {
+	if len(q.segments) == 0 {
+		return false
+	}
+	last := q.segments[len(q.segments)-1]
+	return last.kind == segmentFilter && last.filter.all
+}
+
+// compareOrdered compares two values of the same primitive type using one of the ordering operators.
+func compareOrdered(got, want cty.Value, op string) bool {
+	var cmp int
+	switch got.Type() {
+	case cty.Number:
+		cmp = got.AsBigFloat().Cmp(want.AsBigFloat())
+	case cty.String:
+		cmp = strings.Compare(got.AsString(), want.AsString())
+	default:
+		return false
+	}
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// walkFilter is a supporting function of CompiledQuery.Eval that handles filter segments.
+// Filters apply to the elements of lists, tuples and sets, and to the values of maps and objects.
+func (q *CompiledQuery) walkFilter(val cty.Value, path cty.Path, segments []querySegment) (queryResult, error) {
+	seg := segments[0]
+	if !val.IsKnown() {
+		return unknownResult(path), nil
+	}
+	ty := val.Type()
+	if !ty.IsListType() && !ty.IsTupleType() && !ty.IsSetType() && !ty.IsMapType() && !ty.IsObjectType() {
+		return queryResult{}, fmt.Errorf("query segment %s is a filter operation but value is not a collection", seg.text)
+	}
+	if val.IsNull() {
+		return queryResult{}, NewQueryErrorNotFound(seg.rest)
+	}
+	results := make([]queryResult, 0, val.LengthInt())
+	it := val.ElementIterator()
+	for it.Next() {
+		k, v := it.Element()
+		if !seg.filter.matches(v) {
+			continue
+		}
+		r, err := q.walk(v, elementPath(path, ty, k, v), segments[1:])
+		if err != nil {
+			return queryResult{}, err
+		}
+		if !seg.filter.all {
+			return r, nil
+		}
+		results = append(results, r)
+	}
+	if !seg.filter.all {
+		return queryResult{}, NewQueryErrorNotFound(seg.rest)
+	}
+	return combineResults(results), nil
+}
@@ -0,0 +1,112 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Match is a value found by a query, together with the concrete path to the value.
+type Match struct {
+	Path  cty.Path  // The path from the queried value to the matched value.
+	Value cty.Value // The matched value.
+}
+
+// FormatPath formats a path for use in messages, e.g. `properties.subnets[2].name`.
+// Object attributes are separated by dots, list and tuple indexes are in brackets and map keys are quoted in brackets.
+// Set elements are indexed by their value, which is formatted like HCL, e.g. `rules[{name = "a", port = 80}]`.
+func FormatPath(path cty.Path) string {
+	var sb strings.Builder
+	for _, step := range path {
+		switch s := step.(type) {
+		case cty.GetAttrStep:
+			if sb.Len() > 0 {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(s.Name)
+		case cty.IndexStep:
+			sb.WriteString(fmt.Sprintf("[%s]", fmtPathKey(s.Key)))
+		}
+	}
+	return sb.String()
+}
+
+// fmtPathKey formats the key of an index step, with strings quoted and objects, maps and collections in HCL syntax.
+func fmtPathKey(key cty.Value) string {
+	if !key.IsKnown() || key.IsNull() {
+		return fmtCty(key)
+	}
+	ty := key.Type()
+	switch {
+	case ty == cty.String:
+		return strconv.Quote(key.AsString())
+	case ty.IsObjectType() || ty.IsMapType():
+		parts := make([]string, 0, key.LengthInt())
+		it := key.ElementIterator()
+		for it.Next() {
+			k, v := it.Element()
+			parts = append(parts, fmt.Sprintf("%s = %s", k.AsString(), fmtPathKey(v)))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case ty.IsListType() || ty.IsTupleType() || ty.IsSetType():
+		parts := make([]string, 0, key.LengthInt())
+		it := key.ElementIterator()
+		for it.Next() {
+			_, v := it.Element()
+			parts = append(parts, fmtPathKey(v))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	return fmtCty(key)
+}
+
+// Descendants finds every object attribute or map key with the given name, at any depth of the value.
+// Matches are returned depth first, with keys in lexical order and list members in index order.
+// If a matched value contains further matches, these are also returned.
+func Descendants(val cty.Value, name string) []Match {
+	return descendants(val, name, cty.Path{}, nil)
+}
+
+// descendants is the recursive implementation of Descendants.
+func descendants(val cty.Value, name string, path cty.Path, matches []Match) []Match {
+	if val.IsNull() {
+		return matches
+	}
+	ty := val.Type()
+	if !val.IsKnown() {
+		if ty.IsObjectType() && ty.HasAttribute(name) {
+			matches = append(matches, Match{Path: path.GetAttr(name), Value: val.GetAttr(name)})
+		}
+		return matches
+	}
+	if !ty.IsObjectType() && !ty.IsMapType() && !ty.IsListType() && !ty.IsTupleType() && !ty.IsSetType() {
+		return matches
+	}
+	it := val.ElementIterator()
+	for it.Next() {
+		k, v := it.Element()
+		next := elementPath(path, ty, k, v)
+		if (ty.IsObjectType() || ty.IsMapType()) && k.AsString() == name {
+			matches = append(matches, Match{Path: next, Value: v})
+		}
+		matches = descendants(v, name, next, matches)
+	}
+	return matches
+}
+
+// elementPath returns the path to an element of a collection, from the key and value returned by its element iterator.
+// Object attributes are attribute steps, set elements are indexed by their value and all others by their key.
+func elementPath(path cty.Path, ty cty.Type, key, elem cty.Value) cty.Path {
+	switch {
+	case ty.IsObjectType():
+		return path.GetAttr(key.AsString())
+	case ty.IsSetType():
+		return path.Index(elem)
+	}
+	return path.Index(key)
+}
@@ -0,0 +1,199 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestDescendants(t *testing.T) {
+	in := cty.ObjectVal(map[string]cty.Value{
+		"properties": cty.ObjectVal(map[string]cty.Value{
+			"publicNetworkAccess": cty.StringVal("Disabled"),
+			"siteConfig": cty.ObjectVal(map[string]cty.Value{
+				"publicNetworkAccess": cty.StringVal("Enabled"),
+			}),
+			"slots": cty.TupleVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("a")}),
+				cty.ObjectVal(map[string]cty.Value{"publicNetworkAccess": cty.StringVal("Enabled")}),
+			}),
+			"tags": cty.MapVal(map[string]cty.Value{
+				"publicNetworkAccess": cty.StringVal("tag"),
+			}),
+			"unknown": cty.UnknownVal(cty.Object(map[string]cty.Type{"publicNetworkAccess": cty.String})),
+		}),
+	})
+	matches := Descendants(in, "publicNetworkAccess")
+	require.Equal(t, []Match{
+		{
+			Path:  cty.GetAttrPath("properties").GetAttr("publicNetworkAccess"),
+			Value: cty.StringVal("Disabled"),
+		},
+		{
+			Path:  cty.GetAttrPath("properties").GetAttr("siteConfig").GetAttr("publicNetworkAccess"),
+			Value: cty.StringVal("Enabled"),
+		},
+		{
+			Path:  cty.GetAttrPath("properties").GetAttr("slots").IndexInt(1).GetAttr("publicNetworkAccess"),
+			Value: cty.StringVal("Enabled"),
+		},
+		{
+			Path:  cty.GetAttrPath("properties").GetAttr("tags").IndexString("publicNetworkAccess"),
+			Value: cty.StringVal("tag"),
+		},
+		{
+			Path:  cty.GetAttrPath("properties").GetAttr("unknown").GetAttr("publicNetworkAccess"),
+			Value: cty.UnknownVal(cty.String),
+		},
+	}, matches)
+	require.Empty(t, Descendants(in, "notExist"))
+}
+
+func TestFormatPath(t *testing.T) {
+	testCases := []struct {
+		desc string
+		in   cty.Path
+		out  string
+	}{
+		{
+			desc: "empty",
+			in:   cty.Path{},
+			out:  "",
+		},
+		{
+			desc: "attributes and index",
+			in:   cty.GetAttrPath("properties").GetAttr("subnets").IndexInt(2).GetAttr("name"),
+			out:  "properties.subnets[2].name",
+		},
+		{
+			desc: "map key",
+			in:   cty.GetAttrPath("tags").IndexString("env"),
+			out:  `tags["env"]`,
+		},
+		{
+			desc: "set of strings",
+			in:   cty.GetAttrPath("zones").Index(cty.StringVal("a")),
+			out:  `zones["a"]`,
+		},
+		{
+			desc: "set of objects",
+			in: cty.GetAttrPath("rules").Index(cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.StringVal("a"),
+				"ports": cty.ListVal([]cty.Value{cty.NumberIntVal(80), cty.NumberIntVal(443)}),
+			})).GetAttr("name"),
+			out: `rules[{name = "a", ports = [80, 443]}].name`,
+		},
+		{
+			desc: "leading index",
+			in:   cty.IndexIntPath(0).GetAttr("name"),
+			out:  "[0].name",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			require.Equal(t, tC.out, FormatPath(tC.in))
+		})
+	}
+}
+
+func TestQueryCtyMatches(t *testing.T) {
+	subnet := func(name, policy string) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"name":       cty.StringVal(name),
+			"properties": cty.ObjectVal(map[string]cty.Value{"policy": cty.StringVal(policy)}),
+		})
+	}
+	in := cty.ObjectVal(map[string]cty.Value{
+		"subnets": cty.TupleVal([]cty.Value{subnet("a", "Enabled"), subnet("b", "Disabled"), subnet("c", "Enabled")}),
+		"tags":    cty.MapVal(map[string]cty.Value{"env": cty.StringVal("prod")}),
+		"unknown": cty.UnknownVal(cty.List(cty.String)),
+	})
+	testCases := []struct {
+		desc      string
+		query     string
+		out       []Match
+		expectErr bool
+	}{
+		{
+			desc:  "list wildcard",
+			query: "subnets.#.properties.policy",
+			out: []Match{
+				{Path: cty.GetAttrPath("subnets").IndexInt(0).GetAttr("properties").GetAttr("policy"), Value: cty.StringVal("Enabled")},
+				{Path: cty.GetAttrPath("subnets").IndexInt(1).GetAttr("properties").GetAttr("policy"), Value: cty.StringVal("Disabled")},
+				{Path: cty.GetAttrPath("subnets").IndexInt(2).GetAttr("properties").GetAttr("policy"), Value: cty.StringVal("Enabled")},
+			},
+		},
+		{
+			desc:  "filter",
+			query: `subnets.#(properties.policy=="Enabled")#.name`,
+			out: []Match{
+				{Path: cty.GetAttrPath("subnets").IndexInt(0).GetAttr("name"), Value: cty.StringVal("a")},
+				{Path: cty.GetAttrPath("subnets").IndexInt(2).GetAttr("name"), Value: cty.StringVal("c")},
+			},
+		},
+		{
+			desc:  "index",
+			query: "subnets.1.name",
+			out: []Match{
+				{Path: cty.GetAttrPath("subnets").IndexInt(1).GetAttr("name"), Value: cty.StringVal("b")},
+			},
+		},
+		{
+			desc:  "map key wildcard",
+			query: "tags.*",
+			out: []Match{
+				{Path: cty.GetAttrPath("tags").IndexString("env"), Value: cty.StringVal("prod")},
+			},
+		},
+		{
+			desc:  "recursive descent after segment",
+			query: "subnets.0..policy",
+			out: []Match{
+				{Path: cty.GetAttrPath("subnets").IndexInt(0).GetAttr("properties").GetAttr("policy"), Value: cty.StringVal("Enabled")},
+			},
+		},
+		{
+			desc:  "unknown collection",
+			query: "unknown.#",
+			out: []Match{
+				{Path: cty.GetAttrPath("unknown"), Value: cty.DynamicVal},
+			},
+		},
+		{
+			desc:      "not found",
+			query:     "subnets.#.notExist",
+			expectErr: true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			out, err := QueryCtyMatches(in, tC.query)
+			if tC.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tC.out, out)
+		})
+	}
+}
+
+func TestCompareEachMatch(t *testing.T) {
+	matches := []Match{
+		{Path: cty.GetAttrPath("subnets").IndexInt(0).GetAttr("policy"), Value: cty.StringVal("Enabled")},
+		{Path: cty.GetAttrPath("subnets").IndexInt(1).GetAttr("policy"), Value: cty.StringVal("Disabled")},
+	}
+	failures, err := CompareEachMatch(matches, IsOneOf, NewStringResults("Disabled")...)
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	require.Equal(t, matches[0], failures[0].Match)
+	require.Equal(t, "`subnets[0].policy`: returned value `Enabled` not in expected values `[Disabled]`", failures[0].Message)
+
+	failures, err = CompareEachMatch(matches, IsOneOf, NewStringResults("Enabled", "Disabled")...)
+	require.NoError(t, err)
+	require.Empty(t, failures)
+}
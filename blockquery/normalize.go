@@ -0,0 +1,97 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// valuesEqual compares a result with an expected value, normalising the differences in type that arise from evaluating HCL.
+// Lists, tuples and sets are compared by their elements, so `["1", "2"]` in HCL equals a list of strings.
+// If either value is a set then the order of elements is ignored.
+// Maps and objects are compared by their keys and values.
+// Primitive values are converted to the type of the expected value, so each element of a mixed-type tuple is compared on its own terms.
+func valuesEqual(got, want cty.Value) bool {
+	if !got.IsKnown() || !want.IsKnown() || got.IsNull() || want.IsNull() {
+		return false
+	}
+	gotTy, wantTy := got.Type(), want.Type()
+	switch {
+	case isCollectionType(gotTy) && isCollectionType(wantTy):
+		gotElems, _ := collectionElements(got)
+		wantElems, _ := collectionElements(want)
+		if gotTy.IsSetType() || wantTy.IsSetType() {
+			return unorderedEqual(gotElems, wantElems)
+		}
+		return orderedEqual(gotElems, wantElems)
+	case isMappingType(gotTy) && isMappingType(wantTy):
+		return mappingsEqual(got, want)
+	}
+	cnv, err := convert.Convert(got, wantTy)
+	if err != nil {
+		return false
+	}
+	return want.Equals(cnv).True()
+}
+
+// isCollectionType checks if the type is a list, tuple or set.
+func isCollectionType(ty cty.Type) bool {
+	return ty.IsListType() || ty.IsTupleType() || ty.IsSetType()
+}
+
+// isMappingType checks if the type is a map or object.
+func isMappingType(ty cty.Type) bool {
+	return ty.IsMapType() || ty.IsObjectType()
+}
+
+// orderedEqual checks that both slices have equal elements in the same order.
+func orderedEqual(got, want []cty.Value) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if !valuesEqual(got[i], want[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// unorderedEqual checks that each element of got equals a distinct element of want, in any order.
+func unorderedEqual(got, want []cty.Value) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	used := make([]bool, len(want))
+	for _, g := range got {
+		found := false
+		for i, w := range want {
+			if !used[i] && valuesEqual(g, w) {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// mappingsEqual checks that two maps or objects have the same keys with equal values.
+func mappingsEqual(got, want cty.Value) bool {
+	if got.LengthInt() != want.LengthInt() {
+		return false
+	}
+	gotMap, wantMap := got.AsValueMap(), want.AsValueMap()
+	for k, w := range wantMap {
+		g, ok := gotMap[k]
+		if !ok || !valuesEqual(g, w) {
+			return false
+		}
+	}
+	return true
+}
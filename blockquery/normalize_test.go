@@ -0,0 +1,151 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package blockquery
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// evalHCL evaluates an HCL expression, as a rule would when reading a resource body.
+func evalHCL(t *testing.T, src string) cty.Value {
+	expr, diags := hclsyntax.ParseExpression([]byte(src), "test.tf", hcl.InitialPos)
+	require.False(t, diags.HasErrors(), diags.Error())
+	val, diags := expr.Value(nil)
+	require.False(t, diags.HasErrors(), diags.Error())
+	return val
+}
+
+func TestCompareFuncsWithHCLCollections(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		cmpFn     ResultCompareFunc
+		got       cty.Value
+		expected  []cty.Value
+		ok        bool
+		msg       string
+		expectErr bool
+	}{
+		{
+			desc:     "each is one of with tuple",
+			cmpFn:    EachIsOneOf,
+			got:      evalHCL(t, `["1", "2"]`),
+			expected: NewStringResults("1", "2", "3"),
+			ok:       true,
+		},
+		{
+			desc:     "each is one of with tuple failure",
+			cmpFn:    EachIsOneOf,
+			got:      evalHCL(t, `["1", "4"]`),
+			expected: NewStringResults("1", "2", "3"),
+			msg:      "returned values `[1 4]` not in expected values `[1 2 3]`",
+		},
+		{
+			desc:     "each is one of with set",
+			cmpFn:    EachIsOneOf,
+			got:      cty.SetVal([]cty.Value{cty.StringVal("1"), cty.StringVal("2")}),
+			expected: NewStringResults("1", "2", "3"),
+			ok:       true,
+		},
+		{
+			desc:     "each is one of with mixed-type tuple",
+			cmpFn:    EachIsOneOf,
+			got:      evalHCL(t, `["1", 2, true]`),
+			expected: NewStringResults("1", "2", "true"),
+			ok:       true,
+		},
+		{
+			desc:     "each is one of with mixed-type expected values",
+			cmpFn:    EachIsOneOf,
+			got:      evalHCL(t, `[1, "two"]`),
+			expected: append(NewIntResults(1), NewStringResults("three")...),
+			msg:      "returned values `[1 two]` not in expected values `[1 three]`",
+		},
+		{
+			desc:     "each is one of with null tuple",
+			cmpFn:    EachIsOneOf,
+			got:      cty.NullVal(cty.EmptyTuple),
+			expected: NewStringResults("1"),
+			ok:       true,
+		},
+		{
+			desc:      "each is one of with string",
+			cmpFn:     EachIsOneOf,
+			got:       cty.StringVal("1"),
+			expected:  NewStringResults("1"),
+			expectErr: true,
+		},
+		{
+			desc:  "each matches glob with tuple",
+			cmpFn: EachMatchesGlob("Standard_*"),
+			got:   evalHCL(t, `["Standard_LRS", "Standard_GRS"]`),
+			ok:    true,
+		},
+		{
+			desc:     "each greater than with mixed-type tuple",
+			cmpFn:    EachGreaterThan,
+			got:      evalHCL(t, `[8, "9"]`),
+			expected: NewIntResults(7),
+			ok:       true,
+		},
+		{
+			desc:     "is one of tuple equals list",
+			cmpFn:    IsOneOf,
+			got:      evalHCL(t, `["1", "2"]`),
+			expected: NewListResults(NewStringResults("1", "2")),
+			ok:       true,
+		},
+		{
+			desc:     "is one of tuple of numbers equals list of strings",
+			cmpFn:    IsOneOf,
+			got:      evalHCL(t, `[1, 2]`),
+			expected: NewListResults(NewStringResults("1", "2")),
+			ok:       true,
+		},
+		{
+			desc:     "is one of tuple order matters for lists",
+			cmpFn:    IsOneOf,
+			got:      evalHCL(t, `["2", "1"]`),
+			expected: NewListResults(NewStringResults("1", "2")),
+			msg:      "returned value `[2 1]` not in expected values `[[1 2]]`",
+		},
+		{
+			desc:     "is one of tuple equals set in any order",
+			cmpFn:    IsOneOf,
+			got:      evalHCL(t, `["2", "1"]`),
+			expected: []cty.Value{cty.SetVal(NewStringResults("1", "2"))},
+			ok:       true,
+		},
+		{
+			desc:     "is one of object equals map",
+			cmpFn:    IsOneOf,
+			got:      evalHCL(t, `{ env = "prod", count = 1 }`),
+			expected: []cty.Value{cty.MapVal(map[string]cty.Value{"env": cty.StringVal("prod"), "count": cty.StringVal("1")})},
+			ok:       true,
+		},
+		{
+			desc:     "contains all with mixed-type tuple",
+			cmpFn:    ContainsAll,
+			got:      evalHCL(t, `["1", 2, "3"]`),
+			expected: NewIntResults(1, 2, 3),
+			ok:       true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ok, msg, err := tC.cmpFn(tC.got, tC.expected...)
+			if tC.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tC.ok, ok)
+			require.Equal(t, tC.msg, msg)
+		})
+	}
+}
@@ -4,6 +4,7 @@
 package blockquery
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -44,61 +45,249 @@ func Query(val cty.Value, ty cty.Type, query string) (gjson.Result, error) {
 // The query string is a dot-separated list of attribute names.
 // The query string may contain a list index or the hash wildcard (#).
 // The hash wildcard is used to query all elements of a list.
+//
+// A segment may also be a filter in the form `#(path op value)`, which returns the first element
+// where the result of the path query satisfies the comparison, or `#(path op value)#` which returns all of them.
+// The supported operators are `==`, `!=`, `<`, `<=`, `>`, `>=`, `%` (pattern match) and `!%` (pattern does not match).
+// Patterns may use `*` and `?` wildcards. Values must be double-quoted strings, numbers, `true`, `false` or `null`.
+// E.g. `properties.subnets.#(name=="AzureFirewallSubnet").properties.addressPrefix`.
+// Filters can be applied to lists, tuples, sets, maps and objects.
+//
+// The star wildcard (*) is used to query the values of every key of an object or map, in key order.
+//
+// A segment prefixed with two dots, e.g. `..publicNetworkAccess`, finds the attribute at any depth
+// and returns a list of all matches.
+//
+// The query is compiled on every call, use Compile to validate a query once and evaluate it many times.
+// Use QueryCtyMatches to get the concrete path of each value found.
 func QueryCty(val cty.Value, query string) (cty.Value, error) {
-	segment, remaining := nextQuerySegment(query)
-	if i, isList := querySegmentPertainsToList(segment); isList {
-		return queryList(val, i, segment, remaining)
+	q, err := Compile(query)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return q.Eval(val)
+}
+
+// Eval runs the compiled query against the value and returns the result.
+// See QueryCty for the query syntax.
+func (q *CompiledQuery) Eval(val cty.Value) (cty.Value, error) {
+	res, err := q.walk(val, cty.Path{}, q.segments)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return res.value, nil
+}
+
+// EvalMatches runs the compiled query against the value and returns every value found, with its concrete path.
+// Unlike Eval, results of wildcards and filters are not combined into a list, each element is a separate match.
+// If a collection is unknown, a single match is returned for the collection with an unknown value.
+func (q *CompiledQuery) EvalMatches(val cty.Value) ([]Match, error) {
+	res, err := q.walk(val, cty.Path{}, q.segments)
+	if err != nil {
+		return nil, err
+	}
+	return res.matches, nil
+}
+
+// queryResult is the result of running query segments, both as a combined value and as individual matches.
+type queryResult struct {
+	value   cty.Value
+	matches []Match
+}
+
+// combineResults combines the results of running the remaining segments against several elements.
+func combineResults(results []queryResult) queryResult {
+	vals := make([]cty.Value, 0, len(results))
+	matches := make([]Match, 0, len(results))
+	for _, r := range results {
+		vals = append(vals, r.value)
+		matches = append(matches, r.matches...)
+	}
+	return queryResult{value: collectionVal(vals), matches: matches}
+}
+
+// walk runs the remaining segments of the query against the value at the given path.
+func (q *CompiledQuery) walk(val cty.Value, path cty.Path, segments []querySegment) (queryResult, error) {
+	if len(segments) == 0 {
+		return queryResult{value: val, matches: []Match{{Path: path, Value: val}}}, nil
+	}
+	if !val.IsKnown() {
+		return unknownResult(path), nil
+	}
+	seg := segments[0]
+	switch seg.kind {
+	case segmentDescent:
+		return q.walkDescent(val, path, segments)
+	case segmentFilter:
+		return q.walkFilter(val, path, segments)
+	case segmentIndex, segmentListWildcard:
+		return q.walkList(val, path, segments)
 	}
 	if ok := val.Type().IsObjectType() || val.Type().IsMapType(); !ok {
-		return cty.NilVal, fmt.Errorf("query segments remain and value is not an object or map")
+		return queryResult{}, fmt.Errorf("query segments remain and value is not an object or map")
+	}
+	if seg.kind == segmentKeyWildcard {
+		return q.walkKeys(val, path, segments)
+	}
+	next, err := queryKey(val, seg.name, seg.rest)
+	if err != nil {
+		return queryResult{}, err
+	}
+	if val.Type().IsObjectType() {
+		return q.walk(next, path.GetAttr(seg.name), segments[1:])
+	}
+	return q.walk(next, path.IndexString(seg.name), segments[1:])
+}
+
+// unknownResult is the result of running a segment against an unknown value, including cty.DynamicVal,
+// which tflint gives for references to the attributes of other resources.
+func unknownResult(path cty.Path) queryResult {
+	return queryResult{value: cty.DynamicVal, matches: []Match{{Path: path, Value: cty.DynamicVal}}}
+}
+
+// queryKey is a supporting function of CompiledQuery.Eval that returns the value of a single object attribute or map key.
+func queryKey(val cty.Value, name, query string) (cty.Value, error) {
+	if val.IsNull() {
+		return cty.NilVal, NewQueryErrorNotFound(query)
+	}
+	if val.Type().IsObjectType() {
+		if !val.Type().HasAttribute(name) {
+			return cty.NilVal, NewQueryErrorNotFound(query)
+		}
+		return val.GetAttr(name), nil
 	}
-	attrs := val.Type().AttributeTypes()
-	if _, ok := attrs[segment]; !ok {
+	key := cty.StringVal(name)
+	if !val.HasIndex(key).True() {
 		return cty.NilVal, NewQueryErrorNotFound(query)
 	}
-	if remaining == "" {
-		return val.GetAttr(segment), nil
+	return val.Index(key), nil
+}
+
+// walkKeys is a supporting function of CompiledQuery.Eval that handles the star wildcard for objects and maps.
+func (q *CompiledQuery) walkKeys(val cty.Value, path cty.Path, segments []querySegment) (queryResult, error) {
+	if !val.IsKnown() {
+		return unknownResult(path), nil
+	}
+	if val.IsNull() {
+		return queryResult{}, NewQueryErrorNotFound(segments[0].rest)
+	}
+	results := make([]queryResult, 0, val.LengthInt())
+	it := val.ElementIterator()
+	for it.Next() {
+		k, v := it.Element()
+		r, err := q.walk(v, elementPath(path, val.Type(), k, v), segments[1:])
+		if err != nil {
+			return queryResult{}, err
+		}
+		results = append(results, r)
+	}
+	return combineResults(results), nil
+}
+
+// walkDescent is a supporting function of CompiledQuery.Eval that handles recursive descent.
+// Remaining query segments are run against each match, matches that cannot be queried are ignored.
+func (q *CompiledQuery) walkDescent(val cty.Value, path cty.Path, segments []querySegment) (queryResult, error) {
+	matches := descendants(val, segments[0].name, path, nil)
+	results := make([]queryResult, 0, len(matches))
+	var firstErr error
+	for _, m := range matches {
+		r, err := q.walk(m.Value, m.Path, segments[1:])
+		if err != nil {
+			notExistsErr := &QueryErrorNotFound{}
+			if firstErr == nil && !errors.As(err, &notExistsErr) {
+				firstErr = err
+			}
+			continue
+		}
+		results = append(results, r)
+	}
+	if len(results) == 0 {
+		if firstErr != nil {
+			return queryResult{}, firstErr
+		}
+		return queryResult{}, NewQueryErrorNotFound(segments[0].rest)
 	}
-	return QueryCty(val.GetAttr(segment), remaining)
+	return combineResults(results), nil
 }
 
-// queryList is a supporting function of QueryCty that handles list operations.
-func queryList(val cty.Value, i int, segment, remaining string) (cty.Value, error) {
+// walkList is a supporting function of CompiledQuery.Eval that handles list operations.
+func (q *CompiledQuery) walkList(val cty.Value, path cty.Path, segments []querySegment) (queryResult, error) {
+	seg := segments[0]
+	if !val.IsKnown() {
+		return unknownResult(path), nil
+	}
 	if !val.Type().IsListType() && !val.Type().IsTupleType() {
-		return cty.NilVal, fmt.Errorf("query segment %s is a list operation but value is not a list", segment)
+		return queryResult{}, fmt.Errorf("query segment %s is a list operation but value is not a list", seg.text)
 	}
-	// -1 means the query used the hash wildcard
-	if i == -1 {
-		result := make([]cty.Value, 0, val.LengthInt())
+	if val.IsNull() {
+		return queryResult{}, NewQueryErrorNotFound(seg.rest)
+	}
+	if seg.kind == segmentListWildcard {
+		results := make([]queryResult, 0, val.LengthInt())
 		it := val.ElementIterator()
 		for it.Next() {
-			_, v := it.Element()
-			if remaining == "" {
-				result = append(result, v)
-				continue
-			}
-			q, err := QueryCty(v, remaining)
+			k, v := it.Element()
+			r, err := q.walk(v, path.Index(k), segments[1:])
 			if err != nil {
-				return cty.NilVal, err
+				return queryResult{}, err
 			}
-			result = append(result, q)
+			results = append(results, r)
 		}
-		return cty.ListVal(result), nil
+		return combineResults(results), nil
 	}
-	if i >= val.LengthInt() {
-		return cty.NilVal, fmt.Errorf("index %d out of bounds for list of length %d", i, val.LengthInt())
+	if seg.index >= val.LengthInt() {
+		return queryResult{}, fmt.Errorf("index %d out of bounds for list of length %d", seg.index, val.LengthInt())
 	}
-	next := val.Index(cty.NumberIntVal(int64(i)))
-	if remaining == "" {
-		return next, nil
+	return q.walk(val.Index(cty.NumberIntVal(int64(seg.index))), path.IndexInt(seg.index), segments[1:])
+}
+
+// QueryCtyMatches takes a cty value and a query string and returns every value found, with its concrete path.
+// See QueryCty for the query syntax and CompiledQuery.EvalMatches for details of the matches.
+func QueryCtyMatches(val cty.Value, query string) ([]Match, error) {
+	q, err := Compile(query)
+	if err != nil {
+		return nil, err
 	}
-	return QueryCty(next, remaining)
+	return q.EvalMatches(val)
 }
 
 // nextQuerySegment splits a query string into the first segment and the remaining part.
+// Dots inside filter parentheses or quoted strings do not split the query.
+// A double dot is kept at the start of the remaining part as it denotes a recursive descent.
 func nextQuerySegment(query string) (string, string) {
-	before, after, _ := strings.Cut(query, ".")
-	return before, after
+	depth := 0
+	inQuote := false
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case inQuote && c == '\\':
+			i++
+		case c == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == '.' && depth == 0 && strings.HasPrefix(query[i:], ".."):
+			return query[:i], query[i:]
+		case c == '.' && depth == 0:
+			return query[:i], query[i+1:]
+		}
+	}
+	return query, ""
+}
+
+// collectionVal returns a list of the values if they all have the same type, otherwise a tuple.
+func collectionVal(vals []cty.Value) cty.Value {
+	if len(vals) == 0 {
+		return cty.ListValEmpty(cty.DynamicPseudoType)
+	}
+	for _, v := range vals[1:] {
+		if !v.Type().Equals(vals[0].Type()) {
+			return cty.TupleVal(vals)
+		}
+	}
+	return cty.ListVal(vals)
 }
 
 // querySegmentPertainsToList checks if a query segment is a list operation.
@@ -148,6 +148,30 @@ func TestQueryCty(t *testing.T) {
 			out:       cty.UnknownVal(cty.String),
 			expectErr: false,
 		},
+		{
+			desc:  "attribute of dynamic value",
+			in:    cty.ObjectVal(map[string]cty.Value{"properties": cty.DynamicVal}),
+			query: "properties.sku.name",
+			out:   cty.DynamicVal,
+		},
+		{
+			desc:  "list index of dynamic value",
+			in:    cty.ObjectVal(map[string]cty.Value{"subnets": cty.DynamicVal}),
+			query: "subnets.0.name",
+			out:   cty.DynamicVal,
+		},
+		{
+			desc:  "wildcard of dynamic value",
+			in:    cty.ObjectVal(map[string]cty.Value{"identities": cty.DynamicVal}),
+			query: "identities.*",
+			out:   cty.DynamicVal,
+		},
+		{
+			desc:  "filter of dynamic value",
+			in:    cty.ObjectVal(map[string]cty.Value{"subnets": cty.DynamicVal}),
+			query: `subnets.#(name=="a")#.name`,
+			out:   cty.DynamicVal,
+		},
 	}
 	for _, tC := range testCases {
 		t.Run(tC.desc, func(t *testing.T) {
@@ -162,6 +186,333 @@ func TestQueryCty(t *testing.T) {
 	}
 }
 
+func TestQueryCtyKeyWildcard(t *testing.T) {
+	identities := map[string]cty.Value{
+		"/subscriptions/0000/id2": cty.ObjectVal(map[string]cty.Value{"clientId": cty.StringVal("b")}),
+		"/subscriptions/0000/id1": cty.ObjectVal(map[string]cty.Value{"clientId": cty.StringVal("a")}),
+	}
+	testCases := []struct {
+		desc      string
+		in        cty.Value
+		query     string
+		out       cty.Value
+		expectErr bool
+	}{
+		{
+			desc:  "object values in key order",
+			in:    cty.ObjectVal(map[string]cty.Value{"identities": cty.ObjectVal(identities)}),
+			query: "identities.*.clientId",
+			out:   cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		},
+		{
+			desc:  "map values in key order",
+			in:    cty.ObjectVal(map[string]cty.Value{"identities": cty.MapVal(identities)}),
+			query: "identities.*.clientId",
+			out:   cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		},
+		{
+			desc: "object values of different types",
+			in: cty.ObjectVal(map[string]cty.Value{"tags": cty.ObjectVal(map[string]cty.Value{
+				"a": cty.StringVal("x"),
+				"b": cty.NumberIntVal(1),
+			})}),
+			query: "tags.*",
+			out:   cty.TupleVal([]cty.Value{cty.StringVal("x"), cty.NumberIntVal(1)}),
+		},
+		{
+			desc:  "empty map",
+			in:    cty.ObjectVal(map[string]cty.Value{"tags": cty.MapValEmpty(cty.String)}),
+			query: "tags.*",
+			out:   cty.ListValEmpty(cty.DynamicPseudoType),
+		},
+		{
+			desc:  "map key",
+			in:    cty.ObjectVal(map[string]cty.Value{"tags": cty.MapVal(map[string]cty.Value{"env": cty.StringVal("prod")})}),
+			query: "tags.env",
+			out:   cty.StringVal("prod"),
+		},
+		{
+			desc:      "map key not found",
+			in:        cty.ObjectVal(map[string]cty.Value{"tags": cty.MapVal(map[string]cty.Value{"env": cty.StringVal("prod")})}),
+			query:     "tags.owner",
+			expectErr: true,
+		},
+		{
+			desc:      "star on list",
+			in:        cty.ObjectVal(map[string]cty.Value{"list": cty.ListVal([]cty.Value{cty.StringVal("a")})}),
+			query:     "list.*",
+			expectErr: true,
+		},
+		{
+			desc:      "null object",
+			in:        cty.ObjectVal(map[string]cty.Value{"identities": cty.NullVal(cty.Object(map[string]cty.Type{"a": cty.String}))}),
+			query:     "identities.*",
+			expectErr: true,
+		},
+		{
+			desc:  "unknown map",
+			in:    cty.ObjectVal(map[string]cty.Value{"tags": cty.UnknownVal(cty.Map(cty.String))}),
+			query: "tags.*",
+			out:   cty.DynamicVal,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			out, err := QueryCty(tC.in, tC.query)
+			if tC.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tC.out, out)
+		})
+	}
+}
+
+func TestQueryCtyRecursiveDescent(t *testing.T) {
+	in := cty.ObjectVal(map[string]cty.Value{
+		"properties": cty.ObjectVal(map[string]cty.Value{
+			"publicNetworkAccess": cty.StringVal("Disabled"),
+			"siteConfig": cty.ObjectVal(map[string]cty.Value{
+				"publicNetworkAccess": cty.StringVal("Enabled"),
+				"sku": cty.ObjectVal(map[string]cty.Value{
+					"name": cty.StringVal("Standard"),
+				}),
+			}),
+		}),
+		"sku": cty.StringVal("Basic"),
+	})
+	testCases := []struct {
+		desc      string
+		query     string
+		out       cty.Value
+		expectErr bool
+	}{
+		{
+			desc:  "all depths",
+			query: "..publicNetworkAccess",
+			out:   cty.ListVal([]cty.Value{cty.StringVal("Disabled"), cty.StringVal("Enabled")}),
+		},
+		{
+			desc:  "after a segment",
+			query: "properties.siteConfig..publicNetworkAccess",
+			out:   cty.ListVal([]cty.Value{cty.StringVal("Enabled")}),
+		},
+		{
+			desc:  "with remaining segments ignores matches without them",
+			query: "..sku.name",
+			out:   cty.ListVal([]cty.Value{cty.StringVal("Standard")}),
+		},
+		{
+			desc:      "not found",
+			query:     "..notExist",
+			expectErr: true,
+		},
+		{
+			desc:      "no attribute name",
+			query:     "properties..",
+			expectErr: true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			out, err := QueryCty(in, tC.query)
+			if tC.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tC.out, out)
+		})
+	}
+}
+
+func TestQueryCtyFilter(t *testing.T) {
+	subnet := func(name, prefix string, size int) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"name": cty.StringVal(name),
+			"properties": cty.ObjectVal(map[string]cty.Value{
+				"addressPrefix": cty.StringVal(prefix),
+				"size":          cty.NumberIntVal(int64(size)),
+			}),
+		})
+	}
+	subnets := []cty.Value{
+		subnet("default", "10.0.0.0/24", 24),
+		subnet("AzureFirewallSubnet", "10.0.1.0/26", 26),
+		subnet("AzureBastionSubnet", "10.0.2.0/26", 26),
+	}
+	testCases := []struct {
+		desc      string
+		in        cty.Value
+		query     string
+		out       cty.Value
+		expectErr bool
+	}{
+		{
+			desc:  "first match in list",
+			in:    cty.ObjectVal(map[string]cty.Value{"subnets": cty.ListVal(subnets)}),
+			query: `subnets.#(name=="AzureFirewallSubnet").properties.addressPrefix`,
+			out:   cty.StringVal("10.0.1.0/26"),
+		},
+		{
+			desc:  "first match in tuple",
+			in:    cty.ObjectVal(map[string]cty.Value{"subnets": cty.TupleVal(subnets)}),
+			query: `subnets.#(name=="AzureFirewallSubnet").properties.addressPrefix`,
+			out:   cty.StringVal("10.0.1.0/26"),
+		},
+		{
+			desc:  "all matches",
+			in:    cty.ObjectVal(map[string]cty.Value{"subnets": cty.ListVal(subnets)}),
+			query: `subnets.#(properties.size==26)#.name`,
+			out:   cty.ListVal([]cty.Value{cty.StringVal("AzureFirewallSubnet"), cty.StringVal("AzureBastionSubnet")}),
+		},
+		{
+			desc:  "not equal",
+			in:    cty.ObjectVal(map[string]cty.Value{"subnets": cty.ListVal(subnets)}),
+			query: `subnets.#(name!="default")#.name`,
+			out:   cty.ListVal([]cty.Value{cty.StringVal("AzureFirewallSubnet"), cty.StringVal("AzureBastionSubnet")}),
+		},
+		{
+			desc:  "less than",
+			in:    cty.ObjectVal(map[string]cty.Value{"subnets": cty.ListVal(subnets)}),
+			query: `subnets.#(properties.size<26).name`,
+			out:   cty.StringVal("default"),
+		},
+		{
+			desc:  "greater than or equal",
+			in:    cty.ObjectVal(map[string]cty.Value{"subnets": cty.ListVal(subnets)}),
+			query: `subnets.#(properties.size>=26)#.name`,
+			out:   cty.ListVal([]cty.Value{cty.StringVal("AzureFirewallSubnet"), cty.StringVal("AzureBastionSubnet")}),
+		},
+		{
+			desc:  "pattern",
+			in:    cty.ObjectVal(map[string]cty.Value{"subnets": cty.ListVal(subnets)}),
+			query: `subnets.#(name%"Azure*Subnet")#.properties.addressPrefix`,
+			out:   cty.ListVal([]cty.Value{cty.StringVal("10.0.1.0/26"), cty.StringVal("10.0.2.0/26")}),
+		},
+		{
+			desc:  "negated pattern",
+			in:    cty.ObjectVal(map[string]cty.Value{"subnets": cty.ListVal(subnets)}),
+			query: `subnets.#(name!%"Azure*")#.name`,
+			out:   cty.ListVal([]cty.Value{cty.StringVal("default")}),
+		},
+		{
+			desc:  "quoted value containing dots and operators",
+			in:    cty.ObjectVal(map[string]cty.Value{"subnets": cty.ListVal(subnets)}),
+			query: `subnets.#(properties.addressPrefix=="10.0.2.0/26").name`,
+			out:   cty.StringVal("AzureBastionSubnet"),
+		},
+		{
+			desc:  "element filter on set of strings",
+			in:    cty.ObjectVal(map[string]cty.Value{"zones": cty.SetVal([]cty.Value{cty.StringVal("1"), cty.StringVal("2")})}),
+			query: `zones.#(=="2")`,
+			out:   cty.StringVal("2"),
+		},
+		{
+			desc: "filter on map values",
+			in: cty.ObjectVal(map[string]cty.Value{"rules": cty.MapVal(map[string]cty.Value{
+				"a": cty.ObjectVal(map[string]cty.Value{"action": cty.StringVal("Allow")}),
+				"b": cty.ObjectVal(map[string]cty.Value{"action": cty.StringVal("Deny")}),
+			})}),
+			query: `rules.#(action=="Deny").action`,
+			out:   cty.StringVal("Deny"),
+		},
+		{
+			desc: "filter on object values",
+			in: cty.ObjectVal(map[string]cty.Value{"rules": cty.ObjectVal(map[string]cty.Value{
+				"a": cty.ObjectVal(map[string]cty.Value{"priority": cty.NumberIntVal(100)}),
+				"b": cty.ObjectVal(map[string]cty.Value{"priority": cty.NumberIntVal(200)}),
+			})}),
+			query: `rules.#(priority>100)#.priority`,
+			out:   cty.ListVal([]cty.Value{cty.NumberIntVal(200)}),
+		},
+		{
+			desc:  "existence filter",
+			in:    cty.ObjectVal(map[string]cty.Value{"subnets": cty.ListVal(subnets)}),
+			query: `subnets.#(properties.addressPrefix)#.name`,
+			out:   cty.ListVal([]cty.Value{cty.StringVal("default"), cty.StringVal("AzureFirewallSubnet"), cty.StringVal("AzureBastionSubnet")}),
+		},
+		{
+			desc:  "no match for all matches returns empty list",
+			in:    cty.ObjectVal(map[string]cty.Value{"subnets": cty.ListVal(subnets)}),
+			query: `subnets.#(name=="notExist")#`,
+			out:   cty.ListValEmpty(cty.DynamicPseudoType),
+		},
+		{
+			desc: "nested filter",
+			in: cty.ObjectVal(map[string]cty.Value{"vnets": cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("hub"), "subnets": cty.ListVal(subnets)}),
+				cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("spoke"), "subnets": cty.ListVal(subnets[:1])}),
+			})}),
+			query: `vnets.#(subnets.#(name=="AzureFirewallSubnet"))#.name`,
+			out:   cty.ListVal([]cty.Value{cty.StringVal("hub")}),
+		},
+		{
+			desc: "nested filter returning all matches",
+			in: cty.ObjectVal(map[string]cty.Value{"vnets": cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("hub"), "subnets": cty.ListVal(subnets)}),
+				cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("spoke"), "subnets": cty.ListVal(subnets[:1])}),
+			})}),
+			query: `vnets.#(subnets.#(name=="AzureFirewallSubnet")#)#.name`,
+			out:   cty.ListVal([]cty.Value{cty.StringVal("hub")}),
+		},
+		{
+			desc: "nested filter with comparison",
+			in: cty.ObjectVal(map[string]cty.Value{"vnets": cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("hub"), "subnets": cty.ListVal(subnets)}),
+				cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("spoke"), "subnets": cty.ListVal(subnets[:1])}),
+			})}),
+			query: `vnets.#(subnets.#(properties.size==24).name=="default")#.name`,
+			out:   cty.ListVal([]cty.Value{cty.StringVal("hub"), cty.StringVal("spoke")}),
+		},
+		{
+			desc: "nested filter with operators in quoted value",
+			in: cty.ObjectVal(map[string]cty.Value{"vnets": cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("hub"), "subnets": cty.ListVal(subnets)}),
+				cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("spoke"), "subnets": cty.ListVal(subnets[:1])}),
+			})}),
+			query: `vnets.#(subnets.#(name=="a==b"))#.name`,
+			out:   cty.ListValEmpty(cty.DynamicPseudoType),
+		},
+		{
+			desc:      "no match for first match",
+			in:        cty.ObjectVal(map[string]cty.Value{"subnets": cty.ListVal(subnets)}),
+			query:     `subnets.#(name=="notExist").name`,
+			expectErr: true,
+		},
+		{
+			desc:      "filter on primitive",
+			in:        cty.ObjectVal(map[string]cty.Value{"key": cty.StringVal("value")}),
+			query:     `key.#(=="value")`,
+			expectErr: true,
+		},
+		{
+			desc:      "unquoted string value",
+			in:        cty.ObjectVal(map[string]cty.Value{"subnets": cty.ListVal(subnets)}),
+			query:     `subnets.#(name==default)`,
+			expectErr: true,
+		},
+		{
+			desc:      "unterminated filter",
+			in:        cty.ObjectVal(map[string]cty.Value{"subnets": cty.ListVal(subnets)}),
+			query:     `subnets.#(name=="default"`,
+			expectErr: true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			out, err := QueryCty(tC.in, tC.query)
+			if tC.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tC.out, out)
+		})
+	}
+}
+
 func TestNextQuerySegment(t *testing.T) {
 	testCases := []struct {
 		desc      string
@@ -187,6 +538,18 @@ func TestNextQuerySegment(t *testing.T) {
 			segment:   "a",
 			remaining: "b.c",
 		},
+		{
+			desc:      "filter containing dots",
+			in:        `#(b.c=="d.e").f`,
+			segment:   `#(b.c=="d.e")`,
+			remaining: "f",
+		},
+		{
+			desc:      "recursive descent",
+			in:        "a..b.c",
+			segment:   "a",
+			remaining: "..b.c",
+		},
 		{
 			desc:      "empty",
 			in:        "",
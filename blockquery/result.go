@@ -63,3 +63,19 @@ func NewListResults(vals ...[]cty.Value) []cty.Value {
 	}
 	return results
 }
+
+// NewShapeResults creates partial object results from JSON documents, for use with MatchesShape.
+// E.g. If you expect the sku name to be "Standard" regardless of the tier, then use
+// NewShapeResults(`{"sku": {"name": "Standard"}}`).
+// If a document is not valid JSON, it will panic.
+func NewShapeResults(docs ...string) []cty.Value {
+	results := make([]cty.Value, len(docs))
+	for i, doc := range docs {
+		val, err := ShapeFromJSON(doc)
+		if err != nil {
+			panic(err)
+		}
+		results[i] = val
+	}
+	return results
+}
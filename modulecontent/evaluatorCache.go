@@ -0,0 +1,202 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package modulecontent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/terraform-linters/tflint/terraform"
+)
+
+// evaluatorCache holds the loaded configuration and evaluator of each working directory,
+// so that the rules of a ruleset do not each parse the module again.
+type evaluatorCache struct {
+	mu      sync.Mutex
+	entries map[string]*evaluatorCacheEntry
+}
+
+// evaluatorCacheEntry is the cached configuration of a working directory.
+// Its lock is held while the configuration is loaded, so concurrent rules wait for a single load.
+type evaluatorCacheEntry struct {
+	mu          sync.Mutex
+	loaded      *loadedEvaluator
+	fingerprint string // The path, size and modification time of the configuration and variables files when the configuration was loaded.
+	hash        string // The hash of the content of those files.
+}
+
+// loadedEvaluator is a loaded configuration and its evaluator.
+type loadedEvaluator struct {
+	config   *terraform.Config
+	ctx      *terraform.Evaluator
+	sources  map[string]VariableSource // The source of each input variable of the root module.
+	dirs     []string                  // The directories the configuration was loaded from.
+	varFiles []string                  // The variables files that were read.
+}
+
+// sharedEvaluatorCache is used by FetchBlocks and FetchAttributes.
+var sharedEvaluatorCache = &evaluatorCache{
+	entries: make(map[string]*evaluatorCacheEntry),
+}
+
+// ClearEvaluatorCache discards the cached configuration of every working directory.
+// The cache is checked against the content of the configuration files on each use, so this is only needed to free memory.
+func ClearEvaluatorCache() {
+	sharedEvaluatorCache.clear()
+}
+
+// clear discards all entries.
+func (c *evaluatorCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*evaluatorCacheEntry)
+}
+
+// get returns the configuration and evaluator for the working directory and variable options, loading them if they are not cached
+// or if any of the configuration or variables files has been added, removed or changed since they were loaded.
+// Configurations that fail to load are not cached.
+func (c *evaluatorCache) get(wd string, vo variableOptions) (*terraform.Config, *terraform.Evaluator, map[string]VariableSource, hcl.Diagnostics) {
+	cwd, _ := os.Getwd()
+	key := wd + "\x00" + cwd + "\x00" + vo.key()
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &evaluatorCacheEntry{}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if l := entry.loaded; l != nil && entry.unchanged() {
+		return l.config, l.ctx, l.sources, nil
+	}
+	entry.loaded = nil
+	l, diags := loadEvaluator(wd, vo)
+	if diags.HasErrors() {
+		return nil, nil, nil, diags
+	}
+	files, err := configFiles(l.dirs, l.varFiles)
+	if err != nil {
+		return l.config, l.ctx, l.sources, nil
+	}
+	hash, err := hashConfigFiles(files)
+	if err != nil {
+		return l.config, l.ctx, l.sources, nil
+	}
+	entry.loaded, entry.fingerprint, entry.hash = l, configFingerprint(files), hash
+	return l.config, l.ctx, l.sources, nil
+}
+
+// unchanged checks if the configuration and variables files of the loaded entry are unchanged since they were loaded.
+// The size and modification time of the files are compared first, and their content only if those match,
+// as an edit may keep both, e.g. within the resolution of the modification time.
+func (e *evaluatorCacheEntry) unchanged() bool {
+	files, err := configFiles(e.loaded.dirs, e.loaded.varFiles)
+	if err != nil || configFingerprint(files) != e.fingerprint {
+		return false
+	}
+	hash, err := hashConfigFiles(files)
+	return err == nil && hash == e.hash
+}
+
+// configFile is a Terraform configuration or variables file that the cached configuration depends on.
+type configFile struct {
+	path string
+	info os.FileInfo
+}
+
+// configFiles returns the Terraform configuration and variables files in the directories, and the given files.
+func configFiles(dirs, files []string) ([]configFile, error) {
+	var result []configFile
+	for _, dir := range dirs {
+		infos, err := AppFs.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if info.IsDir() || !isConfigFile(info.Name()) {
+				continue
+			}
+			result = append(result, configFile{path: filepath.Join(dir, info.Name()), info: info})
+		}
+	}
+	for _, file := range files {
+		info, err := AppFs.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, configFile{path: file, info: info})
+	}
+	return result, nil
+}
+
+// configFingerprint returns the path, size and modification time of the files,
+// so that adding, removing or changing any of them usually changes the fingerprint without reading the files.
+func configFingerprint(files []configFile) string {
+	var sb strings.Builder
+	for _, f := range files {
+		fmt.Fprintf(&sb, "%s\x00%d\x00%d\x00", f.path, f.info.Size(), f.info.ModTime().UnixNano())
+	}
+	return sb.String()
+}
+
+// hashConfigFiles returns a hash of the paths and content of the files.
+func hashConfigFiles(files []configFile) (string, error) {
+	h := sha256.New()
+	for _, f := range files {
+		if err := hashFile(h, f.path); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile writes the path and content of the file to the hash.
+func hashFile(h io.Writer, path string) error {
+	f, err := AppFs.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, _ = io.WriteString(h, path+"\x00")
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	_, _ = h.Write([]byte{0})
+	return nil
+}
+
+// configDirs returns the sorted directories of the loaded configuration files, always including the root module directory.
+func configDirs(sources map[string][]byte) []string {
+	seen := map[string]bool{".": true}
+	dirs := []string{"."}
+	for path := range sources {
+		dir := filepath.Dir(path)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// isConfigFile checks if the file is a Terraform configuration or variables file, which can change the loaded configuration.
+func isConfigFile(name string) bool {
+	for _, suffix := range []string{".tf", ".tf.json", ".tfvars", ".tfvars.json"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
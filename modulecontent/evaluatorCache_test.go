@@ -0,0 +1,186 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package modulecontent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
+)
+
+func TestEvaluatorCacheReuse(t *testing.T) {
+	fs := mockFs(`
+resource "azapi_resource" "test" {
+	type = "testType@0000-00-00"
+}`)
+	stub := gostub.Stub(&AppFs, fs)
+	defer stub.Reset()
+	wd, _ := os.Getwd()
+	cache := &evaluatorCache{entries: make(map[string]*evaluatorCacheEntry)}
+
+	_, first, _, diags := cache.get(wd, variableOptions{})
+	require.False(t, diags.HasErrors(), diags.Error())
+	_, second, _, diags := cache.get(wd, variableOptions{})
+	require.False(t, diags.HasErrors(), diags.Error())
+	assert.Same(t, first, second)
+
+	require.NoError(t, fs.WriteFile("main.tf", []byte(`resource "azapi_resource" "changed" {}`), os.ModePerm))
+	_, changed, _, diags := cache.get(wd, variableOptions{})
+	require.False(t, diags.HasErrors(), diags.Error())
+	assert.NotSame(t, second, changed)
+
+	require.NoError(t, fs.WriteFile("variables.tf", []byte(`variable "added" {}`), os.ModePerm))
+	_, added, _, diags := cache.get(wd, variableOptions{})
+	require.False(t, diags.HasErrors(), diags.Error())
+	assert.NotSame(t, changed, added)
+
+	require.NoError(t, fs.WriteFile("README.md", []byte(`# Not configuration`), os.ModePerm))
+	_, unchanged, _, diags := cache.get(wd, variableOptions{})
+	require.False(t, diags.HasErrors(), diags.Error())
+	assert.Same(t, added, unchanged)
+
+	cache.clear()
+	_, cleared, _, diags := cache.get(wd, variableOptions{})
+	require.False(t, diags.HasErrors(), diags.Error())
+	assert.NotSame(t, unchanged, cleared)
+}
+
+func TestEvaluatorCacheSameSizeAndModTime(t *testing.T) {
+	fs := mockFs(`
+resource "azapi_resource" "test" {
+	type = "testType@0000-00-00"
+}`)
+	stub := gostub.Stub(&AppFs, fs)
+	defer stub.Reset()
+	wd, _ := os.Getwd()
+	cache := &evaluatorCache{entries: make(map[string]*evaluatorCacheEntry)}
+
+	_, first, _, diags := cache.get(wd, variableOptions{})
+	require.False(t, diags.HasErrors(), diags.Error())
+	info, err := fs.Stat("main.tf")
+	require.NoError(t, err)
+	require.NoError(t, fs.WriteFile("main.tf", []byte(`
+resource "azapi_resource" "best" {
+	type = "testType@0000-00-00"
+}`), os.ModePerm))
+	require.NoError(t, fs.Chtimes("main.tf", info.ModTime(), info.ModTime()))
+	_, changed, _, diags := cache.get(wd, variableOptions{})
+	require.False(t, diags.HasErrors(), diags.Error())
+	assert.NotSame(t, first, changed)
+}
+
+func TestEvaluatorCacheLoadError(t *testing.T) {
+	fs := mockFs(`resource "azapi_resource" "test" {`)
+	stub := gostub.Stub(&AppFs, fs)
+	defer stub.Reset()
+	wd, _ := os.Getwd()
+	cache := &evaluatorCache{entries: make(map[string]*evaluatorCacheEntry)}
+
+	_, ctx, _, diags := cache.get(wd, variableOptions{})
+	require.True(t, diags.HasErrors())
+	assert.Nil(t, ctx)
+
+	require.NoError(t, fs.WriteFile("main.tf", []byte(`resource "azapi_resource" "test" {}`), os.ModePerm))
+	_, ctx, _, diags = cache.get(wd, variableOptions{})
+	require.False(t, diags.HasErrors(), diags.Error())
+	assert.NotNil(t, ctx)
+}
+
+func TestEvaluatorCacheConcurrent(t *testing.T) {
+	stub := gostub.Stub(&AppFs, mockFs(`
+resource "azapi_resource" "test" {
+	type = "testType@0000-00-00"
+}`))
+	defer stub.Reset()
+	wd, _ := os.Getwd()
+	cache := &evaluatorCache{entries: make(map[string]*evaluatorCacheEntry)}
+
+	const n = 16
+	var wg sync.WaitGroup
+	results := make([]any, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, ctx, _, _ := cache.get(wd, variableOptions{})
+			results[i] = ctx
+		}(i)
+	}
+	wg.Wait()
+	for i := 1; i < n; i++ {
+		assert.Same(t, results[0], results[i])
+	}
+}
+
+// benchmarkContent is a module with enough resources for parsing to dominate a FetchBlocks call.
+func benchmarkContent() string {
+	var sb strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&sb, `
+resource "azapi_resource" "test%d" {
+	type = "Microsoft.Storage/storageAccounts@2023-01-01"
+	name = "test%d"
+	body = {
+		properties = {
+			minimumTlsVersion = "TLS1_2"
+		}
+	}
+}
+`, i, i)
+	}
+	return sb.String()
+}
+
+func BenchmarkFetchBlocksCached(b *testing.B) {
+	benchmarkFetchBlocks(b, false)
+}
+
+func BenchmarkFetchBlocksUncached(b *testing.B) {
+	benchmarkFetchBlocks(b, true)
+}
+
+// benchmarkFetchBlocks runs FetchBlocks as each rule of a ruleset would, optionally clearing the cache before each call.
+func benchmarkFetchBlocks(b *testing.B, clear bool) {
+	stub := gostub.Stub(&AppFs, mockFs(benchmarkContent()))
+	defer stub.Reset()
+	ClearEvaluatorCache()
+	defer ClearEvaluatorCache()
+	wd, _ := os.Getwd()
+	runner := &wdRunner{wd: wd}
+	f := benchmarkFetcher{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if clear {
+			ClearEvaluatorCache()
+		}
+		if _, _, diags := FetchBlocks(f, runner); diags.HasErrors() {
+			b.Fatal(diags)
+		}
+	}
+}
+
+// benchmarkFetcher fetches azapi resources without the bookkeeping of a mock, which would dominate the benchmark.
+type benchmarkFetcher struct{}
+
+func (benchmarkFetcher) BlockType() string    { return "resource" }
+func (benchmarkFetcher) LabelOne() string     { return "azapi_resource" }
+func (benchmarkFetcher) LabelNames() []string { return []string{"type", "name"} }
+func (benchmarkFetcher) Attributes() []string { return []string{"type", "body"} }
+
+// wdRunner is a runner that only reports its working directory, as helper.TestRunner cannot be used in benchmarks.
+type wdRunner struct {
+	tflint.Runner
+	wd string
+}
+
+func (r *wdRunner) GetOriginalwd() (string, error) {
+	return r.wd, nil
+}
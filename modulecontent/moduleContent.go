@@ -23,14 +23,50 @@ var AppFs = afero.Afero{
 // retrieve the resources and attributes of a given resource type.
 type BlockFetcher interface {
 	BlockType() string    // The type of block to fetch, e.g. `resource`.
-	LabelOne() string     // The value of the first label of the block to fetch, e.g. `azapi_resource`.
+	LabelOne() string     // The value of the first label of the block to fetch, e.g. `azapi_resource`, or empty for any label.
 	LabelNames() []string // The labels of the block to fetch, e.g. `["type", "name"]` for Terraform resources.
 	Attributes() []string // The attributes to fetch from the block.
 }
 
+// LabelOnesFetcher is an optional interface for a BlockFetcher that fetches blocks with any of several first labels.
+// If a BlockFetcher implements it, LabelOnes is used instead of LabelOne.
+type LabelOnesFetcher interface {
+	LabelOnes() []string // The values of the first label of the blocks to fetch, e.g. `["azapi_resource", "azapi_update_resource"]`.
+}
+
+// BlockTypesFetcher is an optional interface for a BlockFetcher that fetches several types of block in one pass,
+// e.g. `resource`, `data` and `ephemeral` blocks with the same labels.
+// If a BlockFetcher implements it, BlockTypes is used instead of BlockType.
+type BlockTypesFetcher interface {
+	BlockTypes() []string // The types of block to fetch, e.g. `["resource", "data", "ephemeral"]`.
+}
+
+// NestedSchemaFetcher is an optional interface for a BlockFetcher that fetches nested blocks, e.g. `network_rules` or `lifecycle`.
+// If a BlockFetcher implements it, the nested blocks are returned in the body of each block, with `dynamic` blocks expanded
+// into a block for each element and their iterator bound in the expressions of their attributes.
+// Meta blocks, e.g. `lifecycle`, are returned as written, see metaBlockTypes.
+type NestedSchemaFetcher interface {
+	NestedSchema() []hclext.BlockSchema // The schemas of the nested blocks to fetch, which may have nested blocks of their own.
+}
+
+// NestedBlocks returns the blocks found by following the block types from the block, e.g. `"site_config", "ip_restriction"`.
+// The block must have been fetched with the schemas of the nested blocks, see NestedSchemaFetcher.
+func NestedBlocks(block *hclext.Block, blockTypes ...string) hclext.Blocks {
+	blocks := hclext.Blocks{block}
+	for _, blockType := range blockTypes {
+		var nested hclext.Blocks
+		for _, b := range blocks {
+			nested = append(nested, b.Body.Blocks.OfType(blockType)...)
+		}
+		blocks = nested
+	}
+	return blocks
+}
+
 // FetchResources fetches the attributes of given resource type and the attribute if they exist.
-func FetchAttributes(f BlockFetcher, runner tflint.Runner) (*terraform.Evaluator, []*hclext.Attribute, hcl.Diagnostics) {
-	config, ctx, diags := initEvaluator(runner)
+// Use the variable options, e.g. WithVarFiles, to evaluate the module with other input variables.
+func FetchAttributes(f BlockFetcher, runner tflint.Runner, opts ...FetchOption) (*terraform.Evaluator, []*hclext.Attribute, hcl.Diagnostics) {
+	config, ctx, _, diags := initEvaluator(runner, newFetchOptions(opts))
 	if diags.HasErrors() {
 		return nil, nil, diags
 	}
@@ -39,8 +75,9 @@ func FetchAttributes(f BlockFetcher, runner tflint.Runner) (*terraform.Evaluator
 }
 
 // FetchBlocks returns a slice of resources with the given resource type and the attribute if they exist.
-func FetchBlocks(f BlockFetcher, runner tflint.Runner) (*terraform.Evaluator, []*hclext.Block, hcl.Diagnostics) {
-	config, ctx, diags := initEvaluator(runner)
+// Use the variable options, e.g. WithVarFiles, to evaluate the module with other input variables.
+func FetchBlocks(f BlockFetcher, runner tflint.Runner, opts ...FetchOption) (*terraform.Evaluator, []*hclext.Block, hcl.Diagnostics) {
+	config, ctx, _, diags := initEvaluator(runner, newFetchOptions(opts))
 	if diags.HasErrors() {
 		return nil, nil, diags
 	}
@@ -50,14 +87,15 @@ func FetchBlocks(f BlockFetcher, runner tflint.Runner) (*terraform.Evaluator, []
 }
 
 // blocksFilterByLabelOne returns a slice of resources with the given resource type and the attribute if they exist.
-func blocksFilterByLabelOne(ctx *terraform.Evaluator, module *terraform.Module, bf BlockFetcher) ([]*hclext.Block, hcl.Diagnostics) {
-	resources, diags := blocksWithPartialContent(ctx, module, bf)
+// Any extra attributes are fetched as well as those of the BlockFetcher.
+func blocksFilterByLabelOne(ctx *terraform.Evaluator, module *terraform.Module, bf BlockFetcher, extraAttrs ...string) ([]*hclext.Block, hcl.Diagnostics) {
+	resources, diags := blocksWithPartialContent(ctx, module, bf, extraAttrs...)
 	if diags.HasErrors() {
 		return nil, diags
 	}
 	filteredResources := make([]*hclext.Block, 0, len(resources.Blocks))
 	for _, resource := range resources.Blocks {
-		if resource.Labels[0] != bf.LabelOne() {
+		if !matchesLabelOne(resource, bf) {
 			continue
 		}
 		filteredResources = append(filteredResources, resource)
@@ -73,7 +111,7 @@ func getAttributesFilterByLabelOne(ctx *terraform.Evaluator, module *terraform.M
 	}
 	attrs := make([]*hclext.Attribute, 0, len(resources.Blocks))
 	for _, resource := range resources.Blocks {
-		if resource.Labels[0] != bf.LabelOne() {
+		if !matchesLabelOne(resource, bf) {
 			continue
 		}
 		for _, attribute := range bf.Attributes() {
@@ -85,6 +123,24 @@ func getAttributesFilterByLabelOne(ctx *terraform.Evaluator, module *terraform.M
 	return attrs, nil
 }
 
+// matchesLabelOne checks if the first label of the block is one of those requested by the BlockFetcher.
+// An empty LabelOne matches any block, including blocks without labels.
+func matchesLabelOne(block *hclext.Block, bf BlockFetcher) bool {
+	labelOnes := []string{bf.LabelOne()}
+	if lf, ok := bf.(LabelOnesFetcher); ok {
+		labelOnes = lf.LabelOnes()
+	}
+	for _, labelOne := range labelOnes {
+		if labelOne == "" {
+			return true
+		}
+		if len(block.Labels) > 0 && block.Labels[0] == labelOne {
+			return true
+		}
+	}
+	return false
+}
+
 // attrFromBlock returns the attribute with the given attribute name from the block.
 func attrFromBlock(block *hclext.Block, attributeName string) *hclext.Attribute {
 	attribute, exists := block.Body.Attributes[attributeName]
@@ -94,25 +150,37 @@ func attrFromBlock(block *hclext.Block, attributeName string) *hclext.Attribute
 	return attribute
 }
 
-// initEvaluator initializes the evaluator with the given runner.
+// initEvaluator returns the evaluator for the working directory of the runner, and the source of each input variable of the root module.
+// The configuration is loaded once per working directory and variable options, and shared between rules, see evaluatorCache.
+// If the runner is a VarFilesRunner its variables files are used.
+func initEvaluator(runner tflint.Runner, o *fetchOptions) (*terraform.Config, *terraform.Evaluator, map[string]VariableSource, hcl.Diagnostics) {
+	wd, _ := runner.GetOriginalwd()
+	return sharedEvaluatorCache.get(wd, o.variables.withRunnerVarFiles(runner))
+}
+
+// loadEvaluator loads the configuration of the working directory and creates an evaluator for it.
+// The result also has the directories and variables files that the configuration was loaded from.
 // This uses a virtual filesystem to load the Terraform configuration so we can use it in prod and testing.
 // It dows not use the tflint test runner as this limits the tests we can run.
 // e.g. using this we have support for `optional()` evaluation, etc.
-func initEvaluator(runner tflint.Runner) (*terraform.Config, *terraform.Evaluator, hcl.Diagnostics) {
-	wd, _ := runner.GetOriginalwd()
+func loadEvaluator(wd string, vo variableOptions) (*loadedEvaluator, hcl.Diagnostics) {
 	loader, err := terraform.NewLoader(AppFs, wd)
 	if err != nil {
-		return nil, nil, hcl.Diagnostics{{
+		return nil, hcl.Diagnostics{{
 			Summary: err.Error(),
 		}}
 	}
 	config, diags := loader.LoadConfig(".", terraform.CallLocalModule)
 	if diags.HasErrors() {
-		return nil, nil, diags
+		return nil, diags
 	}
-	vvals, diags := terraform.VariableValues(config)
+	inputs, sources, varFiles, diags := inputVariables(loader, config, wd, vo)
 	if diags.HasErrors() {
-		return nil, nil, diags
+		return nil, diags
+	}
+	vvals, diags := terraform.VariableValues(config, inputs...)
+	if diags.HasErrors() {
+		return nil, diags
 	}
 	ctx := &terraform.Evaluator{
 		Meta: &terraform.ContextMeta{
@@ -123,28 +191,90 @@ func initEvaluator(runner tflint.Runner) (*terraform.Config, *terraform.Evaluato
 		VariableValues: vvals,
 		ModulePath:     addrs.RootModuleInstance,
 	}
-	return config, ctx, nil
+	return &loadedEvaluator{
+		config:   config,
+		ctx:      ctx,
+		sources:  sources,
+		dirs:     configDirs(loader.Sources()),
+		varFiles: varFiles,
+	}, nil
 }
 
-// blocksWithPartialContent returns the blocks with the given resource type and the attribute if they exist.
-func blocksWithPartialContent(ctx *terraform.Evaluator, module *terraform.Module, bf BlockFetcher) (*hclext.BodyContent, hcl.Diagnostics) {
-	attrSchema := make([]hclext.AttributeSchema, 0, len(bf.Attributes()))
-	for _, attr := range bf.Attributes() {
+// metaBlockTypes are the nested blocks that configure Terraform rather than the resource, e.g. `lifecycle`.
+// Their expressions may refer to attributes of the resource, e.g. `ignore_changes = [tags]`, so they are fetched without being evaluated.
+var metaBlockTypes = map[string]bool{"lifecycle": true, "connection": true, "provisioner": true}
+
+// blocksWithPartialContent returns the blocks with the given resource type and the attribute if they exist,
+// and their nested blocks if the BlockFetcher is a NestedSchemaFetcher.
+// Blocks with `count` or `for_each` are expanded into a block for each instance, as are `dynamic` blocks.
+// Meta blocks, e.g. `lifecycle`, are returned as written, see metaBlockTypes.
+func blocksWithPartialContent(ctx *terraform.Evaluator, module *terraform.Module, bf BlockFetcher, extraAttrs ...string) (*hclext.BodyContent, hcl.Diagnostics) {
+	attrSchema := make([]hclext.AttributeSchema, 0, len(bf.Attributes())+len(extraAttrs))
+	for _, attr := range append(bf.Attributes(), extraAttrs...) {
 		attrSchema = append(attrSchema, hclext.AttributeSchema{
 			Name:     attr,
 			Required: false,
 		})
 	}
-	resources, diags := module.PartialContent(&hclext.BodySchema{
-		Blocks: []hclext.BlockSchema{
-			{
-				Type:       bf.BlockType(),
-				LabelNames: bf.LabelNames(),
-				Body: &hclext.BodySchema{
-					Attributes: attrSchema,
-				},
+	var nestedSchema, metaSchema []hclext.BlockSchema
+	if nf, ok := bf.(NestedSchemaFetcher); ok {
+		for _, schema := range nf.NestedSchema() {
+			if metaBlockTypes[schema.Type] {
+				metaSchema = append(metaSchema, schema)
+				continue
+			}
+			nestedSchema = append(nestedSchema, schema)
+		}
+	}
+	blockTypes := []string{bf.BlockType()}
+	if tf, ok := bf.(BlockTypesFetcher); ok {
+		blockTypes = tf.BlockTypes()
+	}
+	blockSchema := make([]hclext.BlockSchema, 0, len(blockTypes))
+	for _, blockType := range blockTypes {
+		blockSchema = append(blockSchema, hclext.BlockSchema{
+			Type:       blockType,
+			LabelNames: bf.LabelNames(),
+			Body: &hclext.BodySchema{
+				Attributes: attrSchema,
+				Blocks:     nestedSchema,
 			},
-		},
+		})
+	}
+	resources, diags := module.PartialContent(&hclext.BodySchema{
+		Blocks: blockSchema,
 	}, ctx)
-	return resources, diags
+	if diags.HasErrors() || len(metaSchema) == 0 {
+		return resources, diags
+	}
+	return resources, addMetaBlocks(module, resources.Blocks, blockTypes, bf.LabelNames(), metaSchema)
+}
+
+// addMetaBlocks adds the meta blocks of the schema to each block, fetched from the files of the module without evaluation.
+// The instances of a block share the meta blocks of its definition.
+func addMetaBlocks(module *terraform.Module, blocks hclext.Blocks, blockTypes, labelNames []string, metaSchema []hclext.BlockSchema) hcl.Diagnostics {
+	schema := &hclext.BodySchema{}
+	for _, blockType := range blockTypes {
+		schema.Blocks = append(schema.Blocks, hclext.BlockSchema{
+			Type:       blockType,
+			LabelNames: labelNames,
+			Body:       &hclext.BodySchema{Blocks: metaSchema},
+		})
+	}
+	metaBlocks := make(map[hcl.Range]hclext.Blocks)
+	var diags hcl.Diagnostics
+	for _, file := range module.Files {
+		content, d := hclext.PartialContent(file.Body, schema)
+		diags = diags.Extend(d)
+		for _, block := range content.Blocks {
+			metaBlocks[block.DefRange] = block.Body.Blocks
+		}
+	}
+	if diags.HasErrors() {
+		return diags
+	}
+	for _, block := range blocks {
+		block.Body.Blocks = append(block.Body.Blocks, metaBlocks[block.DefRange]...)
+	}
+	return nil
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/terraform-linters/tflint-plugin-sdk/hclext"
 	"github.com/terraform-linters/tflint-plugin-sdk/helper"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -22,6 +23,14 @@ func mockFs(c string) afero.Afero {
 	return afero.Afero{Fs: fs}
 }
 
+func mockFsFiles(files map[string]string) afero.Afero {
+	fs := afero.NewMemMapFs()
+	for name, c := range files {
+		_ = afero.WriteFile(fs, name, []byte(c), os.ModePerm)
+	}
+	return afero.Afero{Fs: fs}
+}
+
 func TestFetchBlocks(t *testing.T) {
 	mockBlockFetcher := new(MockBlockFetcher)
 	mockBlockFetcher.On("BlockType").Return("resource")
@@ -48,6 +57,471 @@ resource "azapi_resource" "test" {
 	assert.Equal(t, "testType@0000-00-00", val.AsString())
 }
 
+func TestFetchBlocksAnyLabelOne(t *testing.T) {
+	mockBlockFetcher := new(MockBlockFetcher)
+	mockBlockFetcher.On("BlockType").Return("module")
+	mockBlockFetcher.On("LabelOne").Return("")
+	mockBlockFetcher.On("LabelNames").Return([]string{"name"})
+	mockBlockFetcher.On("Attributes").Return([]string{"source"})
+	content := `
+module "one" {
+	source = "Azure/one/azurerm"
+}
+
+module "two" {
+	source = "Azure/two/azurerm"
+}`
+	runner := helper.TestRunner(t, map[string]string{"main.tf": content})
+	stub := gostub.Stub(&AppFs, mockFs(content))
+	defer stub.Reset()
+	_, blocks, diags := FetchBlocks(mockBlockFetcher, runner)
+	if diags.HasErrors() {
+		t.Fatalf("FetchBlocks returned errors: %v", diags)
+	}
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "one", blocks[0].Labels[0])
+	assert.Equal(t, "two", blocks[1].Labels[0])
+}
+
+func TestFetchBlocksLabelOnes(t *testing.T) {
+	mockBlockFetcher := new(MockLabelOnesFetcher)
+	mockBlockFetcher.On("BlockType").Return("resource")
+	mockBlockFetcher.On("LabelOne").Return("azapi_resource")
+	mockBlockFetcher.On("LabelOnes").Return([]string{"azapi_resource", "azapi_update_resource"})
+	mockBlockFetcher.On("LabelNames").Return([]string{"type", "name"})
+	mockBlockFetcher.On("Attributes").Return([]string{"type"})
+	content := `
+resource "azapi_resource" "test" {
+	type = "testType@0000-00-00"
+}
+
+resource "azapi_update_resource" "test" {
+	type = "testType@0000-00-00"
+}
+
+resource "azapi_resource_action" "test" {
+	type = "testType@0000-00-00"
+}`
+	runner := helper.TestRunner(t, map[string]string{"main.tf": content})
+	stub := gostub.Stub(&AppFs, mockFs(content))
+	defer stub.Reset()
+	_, blocks, diags := FetchBlocks(mockBlockFetcher, runner)
+	if diags.HasErrors() {
+		t.Fatalf("FetchBlocks returned errors: %v", diags)
+	}
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "azapi_resource", blocks[0].Labels[0])
+	assert.Equal(t, "azapi_update_resource", blocks[1].Labels[0])
+}
+
+func TestFetchBlocksBlockTypes(t *testing.T) {
+	mockBlockFetcher := new(MockBlockTypesFetcher)
+	mockBlockFetcher.On("BlockType").Return("resource")
+	mockBlockFetcher.On("BlockTypes").Return([]string{"resource", "data"})
+	mockBlockFetcher.On("LabelOne").Return("azapi_resource")
+	mockBlockFetcher.On("LabelNames").Return([]string{"type", "name"})
+	mockBlockFetcher.On("Attributes").Return([]string{"type"})
+	content := `
+resource "azapi_resource" "test" {
+	type = "testType@0000-00-00"
+}
+
+data "azapi_resource" "test" {
+	type = "testType@0000-00-00"
+}
+
+data "azapi_resource_list" "test" {
+	type = "testType@0000-00-00"
+}`
+	runner := helper.TestRunner(t, map[string]string{"main.tf": content})
+	stub := gostub.Stub(&AppFs, mockFs(content))
+	defer stub.Reset()
+	_, blocks, diags := FetchBlocks(mockBlockFetcher, runner)
+	if diags.HasErrors() {
+		t.Fatalf("FetchBlocks returned errors: %v", diags)
+	}
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "resource", blocks[0].Type)
+	assert.Equal(t, "data", blocks[1].Type)
+}
+
+func TestFetchBlocksNestedSchema(t *testing.T) {
+	mockBlockFetcher := new(MockNestedSchemaFetcher)
+	mockBlockFetcher.On("BlockType").Return("resource")
+	mockBlockFetcher.On("LabelOne").Return("azurerm_storage_account")
+	mockBlockFetcher.On("LabelNames").Return([]string{"type", "name"})
+	mockBlockFetcher.On("Attributes").Return([]string{"name"})
+	mockBlockFetcher.On("NestedSchema").Return([]hclext.BlockSchema{
+		{
+			Type: "network_rules",
+			Body: &hclext.BodySchema{
+				Attributes: []hclext.AttributeSchema{{Name: "default_action"}},
+				Blocks: []hclext.BlockSchema{
+					{
+						Type: "private_link_access",
+						Body: &hclext.BodySchema{
+							Attributes: []hclext.AttributeSchema{{Name: "endpoint_resource_id"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "lifecycle",
+			Body: &hclext.BodySchema{
+				Attributes: []hclext.AttributeSchema{{Name: "ignore_changes"}},
+			},
+		},
+	})
+	content := `
+variable "endpoints" {
+	type    = list(string)
+	default = ["one", "two"]
+}
+
+resource "azurerm_storage_account" "test" {
+	name = "test"
+
+	network_rules {
+		default_action = "Deny"
+
+		dynamic "private_link_access" {
+			for_each = var.endpoints
+			content {
+				endpoint_resource_id = "/endpoints/${private_link_access.value}"
+			}
+		}
+	}
+
+	lifecycle {
+		ignore_changes = [tags]
+	}
+}`
+	runner := helper.TestRunner(t, map[string]string{"main.tf": content})
+	stub := gostub.Stub(&AppFs, mockFs(content))
+	defer stub.Reset()
+	ctx, blocks, diags := FetchBlocks(mockBlockFetcher, runner)
+	if diags.HasErrors() {
+		t.Fatalf("FetchBlocks returned errors: %v", diags)
+	}
+	require.Len(t, blocks, 1)
+
+	rules := NestedBlocks(blocks[0], "network_rules")
+	require.Len(t, rules, 1)
+	val, diags := ctx.EvaluateExpr(rules[0].Body.Attributes["default_action"].Expr, cty.String)
+	require.False(t, diags.HasErrors(), diags.Error())
+	assert.Equal(t, "Deny", val.AsString())
+
+	access := NestedBlocks(blocks[0], "network_rules", "private_link_access")
+	require.Len(t, access, 2)
+	for i, expected := range []string{"/endpoints/one", "/endpoints/two"} {
+		val, diags := ctx.EvaluateExpr(access[i].Body.Attributes["endpoint_resource_id"].Expr, cty.String)
+		require.False(t, diags.HasErrors(), diags.Error())
+		assert.Equal(t, expected, val.AsString())
+	}
+
+	lifecycle := NestedBlocks(blocks[0], "lifecycle")
+	require.Len(t, lifecycle, 1)
+	assert.Contains(t, lifecycle[0].Body.Attributes, "ignore_changes")
+
+	assert.Empty(t, NestedBlocks(blocks[0], "identity"))
+}
+
+func TestFetchModuleBlocksChildModules(t *testing.T) {
+	mockBlockFetcher := new(MockBlockFetcher)
+	mockBlockFetcher.On("BlockType").Return("resource")
+	mockBlockFetcher.On("LabelOne").Return("azapi_resource")
+	mockBlockFetcher.On("LabelNames").Return([]string{"type", "name"})
+	mockBlockFetcher.On("Attributes").Return([]string{"type"})
+	files := map[string]string{
+		"main.tf": `
+resource "azapi_resource" "test" {
+	type = "rootType@0000-00-00"
+}
+
+module "child" {
+	source = "./modules/child"
+	type   = "childType@0000-00-00"
+}`,
+		"modules/child/main.tf": `
+variable "type" {
+	type = string
+}
+
+resource "azapi_resource" "test" {
+	type = var.type
+}
+
+module "grandchild" {
+	source = "./grandchild"
+}`,
+		"modules/child/grandchild/main.tf": `
+variable "type" {
+	type    = string
+	default = "grandchildType@0000-00-00"
+}
+
+resource "azapi_resource" "test" {
+	type = var.type
+}`,
+	}
+	runner := helper.TestRunner(t, map[string]string{"main.tf": files["main.tf"]})
+	stub := gostub.Stub(&AppFs, mockFsFiles(files))
+	defer stub.Reset()
+
+	blocks, diags := FetchModuleBlocks(mockBlockFetcher, runner)
+	if diags.HasErrors() {
+		t.Fatalf("FetchModuleBlocks returned errors: %v", diags)
+	}
+	require.Len(t, blocks, 1)
+	assert.Equal(t, "", blocks[0].ModuleAddress)
+
+	blocks, diags = FetchModuleBlocks(mockBlockFetcher, runner, WithChildModules())
+	if diags.HasErrors() {
+		t.Fatalf("FetchModuleBlocks returned errors: %v", diags)
+	}
+	require.Len(t, blocks, 3)
+	expected := []struct {
+		address string
+		typ     string
+	}{
+		{"", "rootType@0000-00-00"},
+		{"module.child", "childType@0000-00-00"},
+		{"module.child.module.grandchild", "grandchildType@0000-00-00"},
+	}
+	for i, e := range expected {
+		assert.Equal(t, e.address, blocks[i].ModuleAddress)
+		val, diags := blocks[i].Evaluator.EvaluateExpr(blocks[i].Body.Attributes["type"].Expr, cty.String)
+		require.False(t, diags.HasErrors(), diags.Error())
+		assert.Equal(t, e.typ, val.AsString())
+	}
+}
+
+func TestFetchModuleBlocksModuleCount(t *testing.T) {
+	mockBlockFetcher := new(MockBlockFetcher)
+	mockBlockFetcher.On("BlockType").Return("resource")
+	mockBlockFetcher.On("LabelOne").Return("azapi_resource")
+	mockBlockFetcher.On("LabelNames").Return([]string{"type", "name"})
+	mockBlockFetcher.On("Attributes").Return([]string{"type"})
+	files := map[string]string{
+		"main.tf": `
+module "disabled" {
+	source = "./modules/child"
+	count  = 0
+}`,
+		"modules/child/main.tf": `
+resource "azapi_resource" "test" {
+	type = "childType@0000-00-00"
+}`,
+	}
+	runner := helper.TestRunner(t, map[string]string{"main.tf": files["main.tf"]})
+	stub := gostub.Stub(&AppFs, mockFsFiles(files))
+	defer stub.Reset()
+
+	blocks, diags := FetchModuleBlocks(mockBlockFetcher, runner, WithChildModules())
+	if diags.HasErrors() {
+		t.Fatalf("FetchModuleBlocks returned errors: %v", diags)
+	}
+	assert.Empty(t, blocks)
+}
+
+func TestFetchModuleBlocksModuleForEach(t *testing.T) {
+	mockBlockFetcher := new(MockBlockFetcher)
+	mockBlockFetcher.On("BlockType").Return("resource")
+	mockBlockFetcher.On("LabelOne").Return("azapi_resource")
+	mockBlockFetcher.On("LabelNames").Return([]string{"type", "name"})
+	mockBlockFetcher.On("Attributes").Return([]string{"type"})
+	files := map[string]string{
+		"main.tf": `
+module "child" {
+	source   = "./modules/child"
+	for_each = toset(["a", "b"])
+	type     = "${each.key}Type@0000-00-00"
+}`,
+		"modules/child/main.tf": `
+variable "type" {
+	type = string
+}
+
+resource "azapi_resource" "test" {
+	type = var.type
+}
+
+module "grandchild" {
+	source = "./grandchild"
+	count  = 1
+}`,
+		"modules/child/grandchild/main.tf": `
+resource "azapi_resource" "test" {
+	type = "grandchildType@0000-00-00"
+}`,
+	}
+	runner := helper.TestRunner(t, map[string]string{"main.tf": files["main.tf"]})
+	stub := gostub.Stub(&AppFs, mockFsFiles(files))
+	defer stub.Reset()
+
+	blocks, diags := FetchModuleBlocks(mockBlockFetcher, runner, WithChildModules())
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.Len(t, blocks, 4)
+	expected := []struct {
+		moduleAddress string
+		address       string
+		typ           string
+	}{
+		{`module.child["a"]`, `module.child["a"].azapi_resource.test`, "aType@0000-00-00"},
+		{`module.child["a"].module.grandchild[0]`, `module.child["a"].module.grandchild[0].azapi_resource.test`, "grandchildType@0000-00-00"},
+		{`module.child["b"]`, `module.child["b"].azapi_resource.test`, "bType@0000-00-00"},
+		{`module.child["b"].module.grandchild[0]`, `module.child["b"].module.grandchild[0].azapi_resource.test`, "grandchildType@0000-00-00"},
+	}
+	for i, e := range expected {
+		assert.Equal(t, e.moduleAddress, blocks[i].ModuleAddress)
+		assert.Equal(t, e.address, blocks[i].Address())
+		val, diags := blocks[i].Evaluator.EvaluateExpr(blocks[i].Body.Attributes["type"].Expr, cty.String)
+		require.False(t, diags.HasErrors(), diags.Error())
+		assert.Equal(t, e.typ, val.AsString())
+	}
+}
+
+func TestFetchModuleBlocksChildModuleInputs(t *testing.T) {
+	files := map[string]string{
+		"main.tf": `
+resource "azapi_resource" "parent" {
+	type = "parentType@0000-00-00"
+}
+
+module "child" {
+	source = "./modules/child"
+	type   = azapi_resource.parent.output.type
+}`,
+		"modules/child/main.tf": `
+variable "type" {
+	type = string
+}
+
+variable "other" {
+	type    = string
+	default = "default"
+}
+
+resource "azapi_resource" "test" {
+	type = var.type
+	name = var.other
+}`,
+	}
+	t.Setenv("TF_VAR_other", "environment")
+	runner := helper.TestRunner(t, map[string]string{"main.tf": files["main.tf"]})
+	stub := gostub.Stub(&AppFs, mockFsFiles(files))
+	defer stub.Reset()
+
+	mockBlockFetcher := new(MockBlockFetcher)
+	mockBlockFetcher.On("BlockType").Return("resource")
+	mockBlockFetcher.On("LabelOne").Return("azapi_resource")
+	mockBlockFetcher.On("LabelNames").Return([]string{"type", "name"})
+	mockBlockFetcher.On("Attributes").Return([]string{"type", "name"})
+	blocks, diags := FetchModuleBlocks(mockBlockFetcher, runner, WithChildModules())
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "module.child", blocks[1].ModuleAddress)
+	val, diags := blocks[1].Evaluator.EvaluateExpr(blocks[1].Body.Attributes["type"].Expr, cty.String)
+	require.False(t, diags.HasErrors(), diags.Error())
+	assert.False(t, val.IsKnown())
+	val, diags = blocks[1].Evaluator.EvaluateExpr(blocks[1].Body.Attributes["name"].Expr, cty.String)
+	require.False(t, diags.HasErrors(), diags.Error())
+	assert.Equal(t, "default", val.AsString())
+}
+
+func TestFetchModuleBlocksChildModuleInputError(t *testing.T) {
+	files := map[string]string{
+		"main.tf": `
+variable "type" {
+	type    = string
+	default = "childType@0000-00-00"
+}
+
+module "child" {
+	source = "./modules/child"
+	type   = var.typo
+}`,
+		"modules/child/main.tf": `
+variable "type" {
+	type = string
+}
+
+resource "azapi_resource" "test" {
+	type = var.type
+}`,
+	}
+	runner := helper.TestRunner(t, map[string]string{"main.tf": files["main.tf"]})
+	stub := gostub.Stub(&AppFs, mockFsFiles(files))
+	defer stub.Reset()
+
+	mockBlockFetcher := new(MockBlockFetcher)
+	mockBlockFetcher.On("BlockType").Return("resource")
+	mockBlockFetcher.On("LabelOne").Return("azapi_resource")
+	mockBlockFetcher.On("LabelNames").Return([]string{"type", "name"})
+	mockBlockFetcher.On("Attributes").Return([]string{"type"})
+	_, diags := FetchModuleBlocks(mockBlockFetcher, runner, WithChildModules())
+	require.True(t, diags.HasErrors())
+	assert.Equal(t, "Reference to undeclared input variable", diags[0].Summary)
+}
+
+func TestFetchModuleBlocksInstances(t *testing.T) {
+	mockBlockFetcher := new(MockBlockFetcher)
+	mockBlockFetcher.On("BlockType").Return("resource")
+	mockBlockFetcher.On("LabelOne").Return("azapi_resource")
+	mockBlockFetcher.On("LabelNames").Return([]string{"type", "name"})
+	mockBlockFetcher.On("Attributes").Return([]string{"type"})
+	content := `
+variable "types" {
+	default = {
+		a = "aType@0000-00-00"
+		b = "bType@0000-00-00"
+	}
+}
+
+resource "azapi_resource" "single" {
+	type = "singleType@0000-00-00"
+}
+
+resource "azapi_resource" "count" {
+	count = 2
+	type  = "countType${count.index}@0000-00-00"
+}
+
+resource "azapi_resource" "each" {
+	for_each = var.types
+	type     = "${each.key}:${each.value}"
+}`
+	runner := helper.TestRunner(t, map[string]string{"main.tf": content})
+	stub := gostub.Stub(&AppFs, mockFs(content))
+	defer stub.Reset()
+	blocks, diags := FetchModuleBlocks(mockBlockFetcher, runner)
+	if diags.HasErrors() {
+		t.Fatalf("FetchModuleBlocks returned errors: %v", diags)
+	}
+	expected := []struct {
+		address string
+		key     cty.Value
+		typ     string
+	}{
+		{`azapi_resource.single`, cty.NilVal, "singleType@0000-00-00"},
+		{`azapi_resource.count[0]`, cty.NumberIntVal(0), "countType0@0000-00-00"},
+		{`azapi_resource.count[1]`, cty.NumberIntVal(1), "countType1@0000-00-00"},
+		{`azapi_resource.each["a"]`, cty.StringVal("a"), "a:aType@0000-00-00"},
+		{`azapi_resource.each["b"]`, cty.StringVal("b"), "b:bType@0000-00-00"},
+	}
+	require.Len(t, blocks, len(expected))
+	for i, e := range expected {
+		assert.Equal(t, e.address, blocks[i].Address())
+		assert.True(t, e.key.RawEquals(blocks[i].InstanceKey), "instance key of %s", e.address)
+		assert.NotContains(t, blocks[i].Body.Attributes, "count")
+		assert.NotContains(t, blocks[i].Body.Attributes, "for_each")
+		val, diags := blocks[i].Evaluator.EvaluateExpr(blocks[i].Body.Attributes["type"].Expr, cty.String)
+		require.False(t, diags.HasErrors(), diags.Error())
+		assert.Equal(t, e.typ, val.AsString())
+	}
+}
+
 // MockBlockFetcher is a mock implementation of BlockFetcher for testing purposes.
 type MockBlockFetcher struct {
 	BlockFetcher
@@ -73,3 +547,33 @@ func (m *MockBlockFetcher) Attributes() []string {
 	args := m.Called()
 	return args.Get(0).([]string)
 }
+
+// MockLabelOnesFetcher is a mock implementation of BlockFetcher and LabelOnesFetcher for testing purposes.
+type MockLabelOnesFetcher struct {
+	MockBlockFetcher
+}
+
+func (m *MockLabelOnesFetcher) LabelOnes() []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
+
+// MockNestedSchemaFetcher is a mock implementation of BlockFetcher and NestedSchemaFetcher for testing purposes.
+type MockNestedSchemaFetcher struct {
+	MockBlockFetcher
+}
+
+func (m *MockNestedSchemaFetcher) NestedSchema() []hclext.BlockSchema {
+	args := m.Called()
+	return args.Get(0).([]hclext.BlockSchema)
+}
+
+// MockBlockTypesFetcher is a mock implementation of BlockFetcher and BlockTypesFetcher for testing purposes.
+type MockBlockTypesFetcher struct {
+	MockBlockFetcher
+}
+
+func (m *MockBlockTypesFetcher) BlockTypes() []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
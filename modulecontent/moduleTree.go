@@ -0,0 +1,346 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package modulecontent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/terraform-linters/tflint-plugin-sdk/hclext"
+	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
+	"github.com/terraform-linters/tflint/terraform"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FetchOption changes how blocks and attributes are fetched.
+type FetchOption func(*fetchOptions)
+
+// fetchOptions are the settings changed by FetchOption.
+type fetchOptions struct {
+	childModules bool
+	variables    variableOptions
+}
+
+// newFetchOptions applies the options to the default settings.
+func newFetchOptions(opts []FetchOption) *fetchOptions {
+	o := &fetchOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithChildModules fetches blocks from the whole module tree rather than only the root module.
+// Child modules are those called with a local source, e.g. `./modules/network`, and their own local children.
+// Each child module is evaluated with the input variables given by its `module` block.
+func WithChildModules() FetchOption {
+	return func(o *fetchOptions) {
+		o.childModules = true
+	}
+}
+
+// ModuleBlock is a block fetched from a module of the configuration, with the evaluator for that module.
+// Blocks with `count` or `for_each` are expanded into a ModuleBlock for each instance, with `count.index`, `each.key` and `each.value` bound.
+type ModuleBlock struct {
+	*hclext.Block
+	ModuleAddress string               // The address of the module instance containing the block, e.g. `module.network` or `module.network["a"]`, or empty for the root module.
+	Evaluator     *terraform.Evaluator // The evaluator for the expressions of the block, with the variables and locals of its module.
+	InstanceKey   cty.Value            // The `count.index` or `each.key` of the instance, or cty.NilVal if the block has neither.
+	// VariableSources is the source of the value of each input variable of the module, e.g. a `.tfvars` file or the calling `module` block.
+	VariableSources map[string]VariableSource
+	module          *terraform.Module // The module containing the block, to follow references to its locals.
+}
+
+// Address returns the address of the block instance, e.g. `module.network.azapi_resource.subnet["a"]` or `data.azapi_resource.test[0]`.
+func (b *ModuleBlock) Address() string {
+	parts := make([]string, 0, len(b.Labels)+2)
+	if b.ModuleAddress != "" {
+		parts = append(parts, b.ModuleAddress)
+	}
+	if b.Type != "resource" {
+		parts = append(parts, b.Type)
+	}
+	parts = append(parts, b.Labels...)
+	return strings.Join(parts, ".") + instanceKeyString(b.InstanceKey)
+}
+
+// ReferencedVariables returns the names of the input variables referred to by the expression, in order of first reference.
+// References to locals are followed, so `local.tags` refers to the variables of the expression of the local.
+func (b *ModuleBlock) ReferencedVariables(expr hcl.Expression) []string {
+	var names []string
+	seen := make(map[string]bool)
+	var visit func(expr hcl.Expression)
+	visit = func(expr hcl.Expression) {
+		for _, traversal := range expr.Variables() {
+			if len(traversal) < 2 {
+				continue
+			}
+			attr, ok := traversal[1].(hcl.TraverseAttr)
+			if !ok {
+				continue
+			}
+			key := traversal.RootName() + "." + attr.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			switch traversal.RootName() {
+			case "var":
+				names = append(names, attr.Name)
+			case "local":
+				if b.module == nil {
+					continue
+				}
+				if local, ok := b.module.Locals[attr.Name]; ok {
+					visit(local.Expr)
+				}
+			}
+		}
+	}
+	visit(expr)
+	return names
+}
+
+// metaArguments are the attributes that expand a block into instances.
+var metaArguments = []string{"count", "for_each"}
+
+// moduleEvaluator is a module instance of the configuration and the evaluator for its expressions.
+type moduleEvaluator struct {
+	address string // The address of the module instance, e.g. `module.network["a"]`, or empty for the root module.
+	config  *terraform.Config
+	ctx     *terraform.Evaluator
+	sources map[string]VariableSource
+}
+
+// FetchModuleBlocks is like FetchBlocks, but returns each block with the address of its module and the evaluator to use for it.
+// By default only the root module is read, use WithChildModules to also read the blocks of child modules.
+func FetchModuleBlocks(f BlockFetcher, runner tflint.Runner, opts ...FetchOption) ([]*ModuleBlock, hcl.Diagnostics) {
+	o := newFetchOptions(opts)
+	config, ctx, sources, diags := initEvaluator(runner, o)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	modules := []moduleEvaluator{{config: config, ctx: ctx, sources: sources}}
+	if o.childModules {
+		children, diags := childModuleEvaluators("", config, ctx)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		modules = append(modules, children...)
+	}
+	var result []*ModuleBlock
+	for _, m := range modules {
+		blocks, diags := blocksFilterByLabelOne(m.ctx, m.config.Module, f, metaArguments...)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		keys, diags := instanceKeys(m.ctx, blocks)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		for i, block := range blocks {
+			removeMetaArguments(block, f)
+			result = append(result, &ModuleBlock{
+				Block:           block,
+				ModuleAddress:   m.address,
+				Evaluator:       m.ctx,
+				InstanceKey:     keys[i],
+				VariableSources: m.sources,
+				module:          m.config.Module,
+			})
+		}
+	}
+	return result, nil
+}
+
+// instanceKeys returns the instance key of each block, or cty.NilVal for blocks without `count` or `for_each`.
+// The instances of a block share its DefRange and are expanded in the order of the elements of `count` or `for_each`,
+// so the keys are found by evaluating the meta-argument once and assigning its keys in order.
+func instanceKeys(ctx *terraform.Evaluator, blocks []*hclext.Block) ([]cty.Value, hcl.Diagnostics) {
+	type instances struct {
+		keys []cty.Value
+		next int
+	}
+	seen := make(map[hcl.Range]*instances)
+	result := make([]cty.Value, len(blocks))
+	for i, block := range blocks {
+		inst, ok := seen[block.DefRange]
+		if !ok {
+			keys, diags := blockInstanceKeys(ctx, block)
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			inst = &instances{keys: keys}
+			seen[block.DefRange] = inst
+		}
+		if inst.next < len(inst.keys) {
+			result[i] = inst.keys[inst.next]
+		}
+		inst.next++
+	}
+	return result, nil
+}
+
+// blockInstanceKeys evaluates the `count` or `for_each` of the block and returns the key of each instance, in order.
+func blockInstanceKeys(ctx *terraform.Evaluator, block *hclext.Block) ([]cty.Value, hcl.Diagnostics) {
+	if attr, ok := block.Body.Attributes["count"]; ok {
+		val, diags := ctx.EvaluateExpr(attr.Expr, cty.Number)
+		if diags.HasErrors() || !val.IsKnown() || val.IsNull() {
+			return nil, diags
+		}
+		n, _ := val.AsBigFloat().Int64()
+		keys := make([]cty.Value, 0, n)
+		for i := int64(0); i < n; i++ {
+			keys = append(keys, cty.NumberIntVal(i))
+		}
+		return keys, nil
+	}
+	if attr, ok := block.Body.Attributes["for_each"]; ok {
+		val, diags := ctx.EvaluateExpr(attr.Expr, cty.DynamicPseudoType)
+		if diags.HasErrors() || !val.IsKnown() || val.IsNull() || !val.CanIterateElements() {
+			return nil, diags
+		}
+		keys := make([]cty.Value, 0, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			k, _ := it.Element()
+			keys = append(keys, k)
+		}
+		return keys, nil
+	}
+	return nil, nil
+}
+
+// removeMetaArguments removes the `count` and `for_each` attributes from the block, unless the BlockFetcher asked for them.
+func removeMetaArguments(block *hclext.Block, bf BlockFetcher) {
+	for _, name := range metaArguments {
+		requested := false
+		for _, attr := range bf.Attributes() {
+			if attr == name {
+				requested = true
+			}
+		}
+		if !requested {
+			delete(block.Body.Attributes, name)
+		}
+	}
+}
+
+// instanceKeyString formats an instance key as in a Terraform address, e.g. `[0]` or `["a"]`.
+func instanceKeyString(key cty.Value) string {
+	if key == cty.NilVal || !key.IsKnown() || key.IsNull() {
+		return ""
+	}
+	if key.Type() == cty.Number {
+		i, _ := key.AsBigFloat().Int64()
+		return fmt.Sprintf("[%d]", i)
+	}
+	if key.Type() == cty.String {
+		return fmt.Sprintf("[%q]", key.AsString())
+	}
+	return ""
+}
+
+// childModuleEvaluators returns the evaluators of the descendants of the module, in order of module name and depth first.
+// A module called with `count` or `for_each` has an evaluator for each instance, addressed by its key, and none if there are no instances.
+// Input variables that are not known until apply are unknown, errors evaluating them are returned.
+func childModuleEvaluators(parentAddress string, parent *terraform.Config, parentCtx *terraform.Evaluator) ([]moduleEvaluator, hcl.Diagnostics) {
+	names := make([]string, 0, len(parent.Children))
+	for name := range parent.Children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var result []moduleEvaluator
+	for _, name := range names {
+		child := parent.Children[name]
+		if child == nil || child.Module == nil {
+			continue
+		}
+		calls, diags := moduleCalls(parent, parentCtx, name, child)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		keys, diags := instanceKeys(parentCtx, calls)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		for i, call := range calls {
+			address := "module." + name + instanceKeyString(keys[i])
+			if parentAddress != "" {
+				address = parentAddress + "." + address
+			}
+			body := call.Body
+			for _, meta := range metaArguments {
+				delete(body.Attributes, meta)
+			}
+			inputs := terraform.InputValues{}
+			sources := make(map[string]VariableSource, len(child.Module.Variables))
+			for varName := range child.Module.Variables {
+				sources[varName] = VariableSource{Kind: VariableSourceDefault}
+			}
+			for varName, attr := range body.Attributes {
+				val, diags := parentCtx.EvaluateExpr(attr.Expr, cty.DynamicPseudoType)
+				if diags.HasErrors() {
+					return nil, diags
+				}
+				inputs[varName] = &terraform.InputValue{Value: val}
+				sources[varName] = VariableSource{Kind: VariableSourceModuleCall, Range: attr.Expr.Range()}
+			}
+			// Unlike the root module, child modules only get the values of their module call and their defaults, not `TF_VAR_*` values.
+			moduleKey := child.Path.UnkeyedInstanceShim().String()
+			vvals := map[string]map[string]cty.Value{moduleKey: {}}
+			for varName, input := range terraform.DefaultVariableValues(child.Module.Variables).Override(inputs) {
+				vvals[moduleKey][varName] = input.Value
+			}
+			ctx := &terraform.Evaluator{
+				Meta:           parentCtx.Meta,
+				Config:         child.Root,
+				VariableValues: vvals,
+				ModulePath:     child.Path.UnkeyedInstanceShim(),
+			}
+			result = append(result, moduleEvaluator{address: address, config: child, ctx: ctx, sources: sources})
+			descendants, diags := childModuleEvaluators(address, child, ctx)
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			result = append(result, descendants...)
+		}
+	}
+	return result, nil
+}
+
+// moduleCalls returns the block of each instance of the named module call,
+// with the attributes for the variables of the child module and its `count` or `for_each`.
+func moduleCalls(parent *terraform.Config, parentCtx *terraform.Evaluator, name string, child *terraform.Config) ([]*hclext.Block, hcl.Diagnostics) {
+	attrSchema := make([]hclext.AttributeSchema, 0, len(child.Module.Variables)+len(metaArguments))
+	for _, meta := range metaArguments {
+		attrSchema = append(attrSchema, hclext.AttributeSchema{Name: meta})
+	}
+	for varName := range child.Module.Variables {
+		attrSchema = append(attrSchema, hclext.AttributeSchema{Name: varName})
+	}
+	calls, diags := parent.Module.PartialContent(&hclext.BodySchema{
+		Blocks: []hclext.BlockSchema{
+			{
+				Type:       "module",
+				LabelNames: []string{"name"},
+				Body: &hclext.BodySchema{
+					Attributes: attrSchema,
+				},
+			},
+		},
+	}, parentCtx)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	var result []*hclext.Block
+	for _, call := range calls.Blocks {
+		if call.Labels[0] == name {
+			result = append(result, call)
+		}
+	}
+	return result, nil
+}
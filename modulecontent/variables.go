@@ -0,0 +1,256 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package modulecontent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
+	"github.com/terraform-linters/tflint/terraform"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// VarFilesRunner is an optional interface for a tflint.Runner that knows the variables files of the run, e.g. those given with `--var-file`.
+// The plugin SDK does not expose the configured files, so wrap the runner to implement it if they are needed.
+type VarFilesRunner interface {
+	VarFiles() []string // The paths of the variables files, in order of precedence.
+}
+
+// VariableSourceKind is the kind of source that set the value of an input variable.
+type VariableSourceKind int
+
+const (
+	VariableSourceDefault     VariableSourceKind = iota // The default value of the variable, or unknown if it has no default.
+	VariableSourceEnvironment                           // A `TF_VAR_*` environment variable.
+	VariableSourceFile                                  // A `.tfvars` or `.tfvars.json` file.
+	VariableSourceOverride                              // A value given with WithVariables.
+	VariableSourceModuleCall                            // An argument of the `module` block calling a child module.
+)
+
+// VariableSource describes where the value of an input variable was set, so that values can be traced in issues and diagnostics.
+type VariableSource struct {
+	Kind  VariableSourceKind
+	Name  string    // The name of the environment variable, or the path of the file, if any.
+	Range hcl.Range // The range of the value in a variables file or module call, if any.
+}
+
+// String returns a description of the source, e.g. `prod.tfvars:3` or `environment variable TF_VAR_sku`.
+func (s VariableSource) String() string {
+	switch s.Kind {
+	case VariableSourceEnvironment:
+		return fmt.Sprintf("environment variable %s", s.Name)
+	case VariableSourceFile:
+		return fmt.Sprintf("%s:%d", s.Range.Filename, s.Range.Start.Line)
+	case VariableSourceOverride:
+		return "override"
+	case VariableSourceModuleCall:
+		return fmt.Sprintf("module call at %s:%d", s.Range.Filename, s.Range.Start.Line)
+	}
+	return "default"
+}
+
+// variableOptions are the values of input variables of the root module given by FetchOption.
+type variableOptions struct {
+	env       []string
+	varFiles  []string
+	overrides map[string]cty.Value
+}
+
+// WithVarFiles sets the values of input variables of the root module from `.tfvars` or `.tfvars.json` files.
+// The files take precedence over `terraform.tfvars`, `*.auto.tfvars` and the files of a VarFilesRunner, and later files over earlier ones.
+func WithVarFiles(files ...string) FetchOption {
+	return func(o *fetchOptions) {
+		o.variables.varFiles = append(o.variables.varFiles, files...)
+	}
+}
+
+// WithEnvironment sets the values of input variables of the root module from `TF_VAR_*` entries of the environment, in the form of os.Environ().
+// This is useful to check the configuration of a pipeline with its environment, the environment of the process is always used.
+func WithEnvironment(env []string) FetchOption {
+	return func(o *fetchOptions) {
+		o.variables.env = append(o.variables.env, env...)
+	}
+}
+
+// WithVariables sets the values of input variables of the root module, taking precedence over any other source.
+// Each name must be declared by the root module.
+func WithVariables(values map[string]cty.Value) FetchOption {
+	return func(o *fetchOptions) {
+		if o.variables.overrides == nil {
+			o.variables.overrides = make(map[string]cty.Value, len(values))
+		}
+		for name, val := range values {
+			o.variables.overrides[name] = val
+		}
+	}
+}
+
+// withRunnerVarFiles returns the options with the variables files of the runner, if it is a VarFilesRunner, before those of the options.
+func (vo variableOptions) withRunnerVarFiles(runner tflint.Runner) variableOptions {
+	vr, ok := runner.(VarFilesRunner)
+	if !ok {
+		return vo
+	}
+	vo.varFiles = append(append([]string{}, vr.VarFiles()...), vo.varFiles...)
+	return vo
+}
+
+// key returns a string that identifies the options, including the `TF_VAR_*` environment of the process.
+func (vo variableOptions) key() string {
+	var sb strings.Builder
+	for _, e := range append(os.Environ(), vo.env...) {
+		if strings.HasPrefix(e, "TF_VAR_") {
+			fmt.Fprintf(&sb, "env:%s\x00", e)
+		}
+	}
+	for _, f := range vo.varFiles {
+		fmt.Fprintf(&sb, "file:%s\x00", f)
+	}
+	names := make([]string, 0, len(vo.overrides))
+	for name := range vo.overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&sb, "var:%s=%s\x00", name, vo.overrides[name].GoString())
+	}
+	return sb.String()
+}
+
+// inputVariables returns the values of the input variables of the root module, in order of precedence, and the source of each variable.
+// The values are taken from the environment, the autoloaded variables files in the root module directory, the variables files and the overrides.
+// The variables files are read by the loader, so their paths are relative to the current directory and named relative to the original working directory.
+// It also returns the variables files that were read. Errors in values name the source of the value.
+func inputVariables(loader *terraform.Loader, config *terraform.Config, wd string, vo variableOptions) ([]terraform.InputValues, map[string]VariableSource, []string, hcl.Diagnostics) {
+	declared := config.Module.Variables
+	sources := make(map[string]VariableSource, len(declared))
+	var diags hcl.Diagnostics
+	for name := range declared {
+		sources[name] = VariableSource{Kind: VariableSourceDefault}
+		if raw, ok := os.LookupEnv("TF_VAR_" + name); ok {
+			// terraform.VariableValues reads these values itself, they are parsed here to name their source in errors.
+			source := VariableSource{Kind: VariableSourceEnvironment, Name: "TF_VAR_" + name}
+			_, envDiags := terraform.ParseVariableValues([]string{name + "=" + raw}, declared)
+			diags = diags.Extend(withVariableSource(envDiags, name, source))
+			sources[name] = source
+		}
+	}
+	var values []terraform.InputValues
+
+	envValues := terraform.InputValues{}
+	for _, e := range vo.env {
+		key, raw, ok := strings.Cut(e, "=")
+		name, isVar := strings.CutPrefix(key, "TF_VAR_")
+		if !ok || !isVar {
+			continue
+		}
+		if _, ok := declared[name]; !ok {
+			continue
+		}
+		source := VariableSource{Kind: VariableSourceEnvironment, Name: key}
+		vals, envDiags := terraform.ParseVariableValues([]string{name + "=" + raw}, declared)
+		diags = diags.Extend(withVariableSource(envDiags, name, source))
+		for k, v := range vals {
+			envValues[k] = v
+		}
+		sources[name] = source
+	}
+	if len(envValues) > 0 {
+		values = append(values, envValues)
+	}
+
+	fileValues, fileDiags := loader.LoadValuesFiles(".", vo.varFiles...)
+	diags = diags.Extend(fileDiags)
+	if fileDiags.HasErrors() {
+		return nil, nil, nil, diags
+	}
+	values = append(values, fileValues...)
+	files := append(autoVarFiles(), vo.varFiles...)
+	baseDir := baseDir(wd)
+	for _, file := range files {
+		for name, source := range varFileSources(loader.Files()[filepath.Join(baseDir, file)]) {
+			sources[name] = source
+		}
+	}
+
+	if len(vo.overrides) > 0 {
+		overrides := make(terraform.InputValues, len(vo.overrides))
+		for name, val := range vo.overrides {
+			if _, ok := declared[name]; !ok {
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Value for undeclared variable",
+					Detail:   fmt.Sprintf("A variable named %q was given as an override, but the root module does not declare a variable of that name.", name),
+				})
+				continue
+			}
+			overrides[name] = &terraform.InputValue{Value: val}
+			sources[name] = VariableSource{Kind: VariableSourceOverride}
+		}
+		values = append(values, overrides)
+	}
+	return values, sources, files, diags
+}
+
+// withVariableSource adds the source of the value of the variable to the detail of the diagnostics.
+func withVariableSource(diags hcl.Diagnostics, name string, source VariableSource) hcl.Diagnostics {
+	for _, diag := range diags {
+		diag.Detail = strings.TrimSpace(fmt.Sprintf("%s The value of var.%s is from %s.", diag.Detail, name, source))
+	}
+	return diags
+}
+
+// autoVarFiles returns the variables files that the loader reads automatically from the root module directory, in order of precedence.
+// Like the loader, it looks for `terraform.tfvars` in the OS filesystem.
+func autoVarFiles() []string {
+	var files []string
+	if _, err := os.Stat("terraform.tfvars"); err == nil {
+		files = append(files, "terraform.tfvars")
+	}
+	infos, err := AppFs.ReadDir(".")
+	if err != nil {
+		return files
+	}
+	var autoFiles []string
+	for _, info := range infos {
+		name := info.Name()
+		if !info.IsDir() && (strings.HasSuffix(name, ".auto.tfvars") || strings.HasSuffix(name, ".auto.tfvars.json")) {
+			autoFiles = append(autoFiles, name)
+		}
+	}
+	sort.Strings(autoFiles)
+	return append(files, autoFiles...)
+}
+
+// baseDir returns the current directory relative to the original working directory, as the loader names files.
+func baseDir(wd string) string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	dir, err := filepath.Rel(wd, cwd)
+	if err != nil {
+		return "."
+	}
+	return dir
+}
+
+// varFileSources returns the source of each value of a variables file loaded by the loader.
+func varFileSources(file *hcl.File) map[string]VariableSource {
+	if file == nil {
+		return nil
+	}
+	attrs, _ := file.Body.JustAttributes()
+	sources := make(map[string]VariableSource, len(attrs))
+	for name, attr := range attrs {
+		rng := attr.Expr.Range()
+		sources[name] = VariableSource{Kind: VariableSourceFile, Name: rng.Filename, Range: rng}
+	}
+	return sources
+}
@@ -0,0 +1,362 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package modulecontent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-linters/tflint-plugin-sdk/helper"
+	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// varFilesRunner is a runner with variables files, as given by `--var-file`.
+type varFilesRunner struct {
+	tflint.Runner
+	files []string
+}
+
+func (r *varFilesRunner) VarFiles() []string {
+	return r.files
+}
+
+func TestFetchModuleBlocksVariables(t *testing.T) {
+	mainTf := `
+variable "sku" {
+	type    = string
+	default = "Basic"
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@0000-00-00"
+	body = {
+		sku = var.sku
+	}
+}`
+	testCases := []struct {
+		name     string
+		files    map[string]string
+		runner   func(tflint.Runner) tflint.Runner
+		env      map[string]string
+		opts     []FetchOption
+		expected string
+		source   string
+	}{
+		{
+			name:     "default",
+			expected: "Basic",
+			source:   "default",
+		},
+		{
+			name:     "process environment",
+			env:      map[string]string{"TF_VAR_sku": "Standard"},
+			expected: "Standard",
+			source:   "environment variable TF_VAR_sku",
+		},
+		{
+			name:     "environment option",
+			opts:     []FetchOption{WithEnvironment([]string{"PATH=/bin", "TF_VAR_sku=Standard", "TF_VAR_undeclared=x"})},
+			expected: "Standard",
+			source:   "environment variable TF_VAR_sku",
+		},
+		{
+			name:     "terraform.tfvars",
+			files:    map[string]string{"terraform.tfvars": `sku = "Standard"`},
+			expected: "Standard",
+			source:   "terraform.tfvars:1",
+		},
+		{
+			name: "auto.tfvars after terraform.tfvars",
+			files: map[string]string{
+				"terraform.tfvars": `sku = "Standard"`,
+				"prod.auto.tfvars": "\nsku = \"Premium\"",
+			},
+			expected: "Premium",
+			source:   "prod.auto.tfvars:2",
+		},
+		{
+			name: "var file",
+			files: map[string]string{
+				"terraform.tfvars": `sku = "Standard"`,
+				"env/prod.tfvars":  `sku = "Premium"`,
+			},
+			opts:     []FetchOption{WithVarFiles("env/prod.tfvars")},
+			expected: "Premium",
+			source:   "env/prod.tfvars:1",
+		},
+		{
+			name:     "json var file",
+			files:    map[string]string{"prod.tfvars.json": `{"sku": "Premium"}`},
+			opts:     []FetchOption{WithVarFiles("prod.tfvars.json")},
+			expected: "Premium",
+			source:   "prod.tfvars.json:1",
+		},
+		{
+			name: "runner var files before option var files",
+			files: map[string]string{
+				"runner.tfvars": `sku = "Standard"`,
+				"option.tfvars": `sku = "Premium"`,
+			},
+			runner: func(r tflint.Runner) tflint.Runner {
+				return &varFilesRunner{Runner: r, files: []string{"runner.tfvars"}}
+			},
+			opts:     []FetchOption{WithVarFiles("option.tfvars")},
+			expected: "Premium",
+			source:   "option.tfvars:1",
+		},
+		{
+			name:  "runner var files",
+			files: map[string]string{"runner.tfvars": `sku = "Standard"`},
+			runner: func(r tflint.Runner) tflint.Runner {
+				return &varFilesRunner{Runner: r, files: []string{"runner.tfvars"}}
+			},
+			expected: "Standard",
+			source:   "runner.tfvars:1",
+		},
+		{
+			name:  "override",
+			files: map[string]string{"terraform.tfvars": `sku = "Standard"`},
+			opts: []FetchOption{
+				WithEnvironment([]string{"TF_VAR_sku=Basic"}),
+				WithVariables(map[string]cty.Value{"sku": cty.StringVal("Premium")}),
+			},
+			expected: "Premium",
+			source:   "override",
+		},
+	}
+
+	for _, c := range testCases {
+		tc := c
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			files := map[string]string{"main.tf": mainTf}
+			for name, content := range tc.files {
+				files[name] = content
+			}
+			chdirFiles(t, files)
+			var runner tflint.Runner = helper.TestRunner(t, map[string]string{"main.tf": mainTf})
+			if tc.runner != nil {
+				runner = tc.runner(runner)
+			}
+
+			blocks, diags := FetchModuleBlocks(newBodyFetcher(), runner, tc.opts...)
+			require.False(t, diags.HasErrors(), diags.Error())
+			require.Len(t, blocks, 1)
+			val, diags := blocks[0].Evaluator.EvaluateExpr(blocks[0].Body.Attributes["body"].Expr, cty.DynamicPseudoType)
+			require.False(t, diags.HasErrors(), diags.Error())
+			assert.Equal(t, tc.expected, val.GetAttr("sku").AsString())
+			assert.Equal(t, tc.source, blocks[0].VariableSources["sku"].String())
+		})
+	}
+}
+
+// chdirRunner is a runner whose original working directory is not the current directory, as with `--chdir`.
+type chdirRunner struct {
+	tflint.Runner
+	wd string
+}
+
+func (r *chdirRunner) GetOriginalwd() (string, error) {
+	return r.wd, nil
+}
+
+func TestFetchModuleBlocksVariablesChdir(t *testing.T) {
+	chdirFiles(t, map[string]string{
+		"sub/main.tf": `
+variable "sku" {
+	type = string
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@0000-00-00"
+	body = {
+		sku = var.sku
+	}
+}`,
+		"sub/terraform.tfvars": `sku = "Standard"`,
+		"sub/prod.tfvars":      `sku = "Premium"`,
+	})
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir("sub"))
+	runner := &chdirRunner{Runner: helper.TestRunner(t, nil), wd: wd}
+
+	blocks, diags := FetchModuleBlocks(newBodyFetcher(), runner, WithVarFiles("prod.tfvars"))
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.Len(t, blocks, 1)
+	val, diags := blocks[0].Evaluator.EvaluateExpr(blocks[0].Body.Attributes["body"].Expr, cty.DynamicPseudoType)
+	require.False(t, diags.HasErrors(), diags.Error())
+	assert.Equal(t, "Premium", val.GetAttr("sku").AsString())
+	assert.Equal(t, filepath.Join("sub", "prod.tfvars")+":1", blocks[0].VariableSources["sku"].String())
+}
+
+func TestFetchModuleBlocksVariablesErrors(t *testing.T) {
+	mainTf := `
+variable "sku" {
+	type = string
+}
+
+variable "tags" {
+	type    = list(string)
+	default = []
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@0000-00-00"
+}`
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		opts    []FetchOption
+		summary string
+		detail  string
+	}{
+		{
+			name:    "undeclared override",
+			opts:    []FetchOption{WithVariables(map[string]cty.Value{"tier": cty.StringVal("Premium")})},
+			summary: "Value for undeclared variable",
+		},
+		{
+			name:    "missing var file",
+			opts:    []FetchOption{WithVarFiles("missing.tfvars")},
+			summary: "Failed to read file",
+		},
+		{
+			name:    "invalid var file",
+			files:   map[string]string{"invalid.tfvars": `sku = `},
+			opts:    []FetchOption{WithVarFiles("invalid.tfvars")},
+			summary: "Missing expression",
+		},
+		{
+			name:    "invalid environment variable",
+			opts:    []FetchOption{WithEnvironment([]string{"TF_VAR_tags=["})},
+			summary: "Missing expression",
+			detail:  "The value of var.tags is from environment variable TF_VAR_tags.",
+		},
+	}
+
+	for _, c := range testCases {
+		tc := c
+		t.Run(tc.name, func(t *testing.T) {
+			files := map[string]string{"main.tf": mainTf}
+			for name, content := range tc.files {
+				files[name] = content
+			}
+			chdirFiles(t, files)
+			runner := helper.TestRunner(t, map[string]string{"main.tf": mainTf})
+
+			_, diags := FetchModuleBlocks(newBodyFetcher(), runner, tc.opts...)
+			require.True(t, diags.HasErrors())
+			assert.Equal(t, tc.summary, diags[0].Summary)
+			assert.Contains(t, diags[0].Detail, tc.detail)
+		})
+	}
+}
+
+func TestFetchModuleBlocksChildModuleVariableSources(t *testing.T) {
+	files := map[string]string{
+		"main.tf": `
+module "child" {
+	source = "./modules/child"
+	sku    = "Premium"
+}`,
+		"modules/child/main.tf": `
+variable "sku" {
+	type = string
+}
+
+variable "tier" {
+	type    = string
+	default = "Standard"
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@0000-00-00"
+	body = {
+		sku  = var.sku
+		tier = var.tier
+	}
+}`,
+	}
+	runner := helper.TestRunner(t, map[string]string{"main.tf": files["main.tf"]})
+	stub := gostub.Stub(&AppFs, mockFsFiles(files))
+	defer stub.Reset()
+
+	blocks, diags := FetchModuleBlocks(newBodyFetcher(), runner, WithChildModules())
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.Len(t, blocks, 1)
+	assert.Equal(t, "module call at main.tf:4", blocks[0].VariableSources["sku"].String())
+	assert.Equal(t, "default", blocks[0].VariableSources["tier"].String())
+}
+
+func TestModuleBlockReferencedVariables(t *testing.T) {
+	mainTf := `
+variable "sku" {
+	type    = string
+	default = "Basic"
+}
+
+variable "tier" {
+	type    = string
+	default = "Standard"
+}
+
+locals {
+	sku = var.sku
+	body = {
+		sku  = local.sku
+		tier = var.tier
+	}
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@0000-00-00"
+	body = merge(local.body, { sku = var.sku })
+}`
+	runner := helper.TestRunner(t, map[string]string{"main.tf": mainTf})
+	stub := gostub.Stub(&AppFs, mockFsFiles(map[string]string{"main.tf": mainTf}))
+	defer stub.Reset()
+
+	blocks, diags := FetchModuleBlocks(newBodyFetcher(), runner)
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.Len(t, blocks, 1)
+	assert.Equal(t, []string{"sku", "tier"}, blocks[0].ReferencedVariables(blocks[0].Body.Attributes["body"].Expr))
+}
+
+// chdirFiles writes the files to a temporary directory and makes it the current directory,
+// as the loader looks for `terraform.tfvars` in the OS filesystem.
+func chdirFiles(t *testing.T, files map[string]string) {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	stub := gostub.Stub(&AppFs, afero.Afero{Fs: afero.NewOsFs()})
+	t.Cleanup(func() {
+		stub.Reset()
+		_ = os.Chdir(wd)
+	})
+}
+
+// newBodyFetcher returns a BlockFetcher for the `type` and `body` of `azapi_resource` resources.
+func newBodyFetcher() *MockBlockFetcher {
+	mockBlockFetcher := new(MockBlockFetcher)
+	mockBlockFetcher.On("BlockType").Return("resource")
+	mockBlockFetcher.On("LabelOne").Return("azapi_resource")
+	mockBlockFetcher.On("LabelNames").Return([]string{"type", "name"})
+	mockBlockFetcher.On("Attributes").Return([]string{"type", "body"})
+	return mockBlockFetcher
+}
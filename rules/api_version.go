@@ -0,0 +1,99 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// apiVersionRegex matches an Azure API version, e.g. `2023-05-01` or `2023-05-01-preview`.
+var apiVersionRegex = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})(?:-([A-Za-z]+))?$`)
+
+// apiVersionSuffixes are the supported stability suffixes, ranked by their order on the same date.
+// A stable version is later than any pre-release version with the same date.
+var apiVersionSuffixes = map[string]int{
+	"privatepreview": 0,
+	"beta":           1,
+	"preview":        2,
+	"":               3,
+}
+
+// ApiVersion is a parsed Azure API version, e.g. `2023-05-01` or `2023-05-01-preview`.
+type ApiVersion struct {
+	Year   int
+	Month  int
+	Day    int
+	Suffix string // The stability suffix in lower case, e.g. `preview`, or empty for a stable version.
+}
+
+// ParseApiVersion parses an Azure API version in the form `YYYY-MM-DD` with an optional `-preview`, `-beta` or `-privatepreview` suffix.
+func ParseApiVersion(s string) (ApiVersion, error) {
+	m := apiVersionRegex.FindStringSubmatch(s)
+	if m == nil {
+		return ApiVersion{}, fmt.Errorf("invalid API version `%s`, expected the form YYYY-MM-DD with an optional suffix", s)
+	}
+	suffix := strings.ToLower(m[4])
+	if _, ok := apiVersionSuffixes[suffix]; !ok {
+		return ApiVersion{}, fmt.Errorf("invalid API version `%s`, unknown suffix `%s`", s, m[4])
+	}
+	date, err := time.Parse("2006-01-02", m[1]+"-"+m[2]+"-"+m[3])
+	if err != nil {
+		return ApiVersion{}, fmt.Errorf("invalid API version `%s`, the date is not valid", s)
+	}
+	year, month, day := date.Year(), int(date.Month()), date.Day()
+	return ApiVersion{Year: year, Month: month, Day: day, Suffix: suffix}, nil
+}
+
+// MustParseApiVersion is like ParseApiVersion but panics if the version is invalid.
+func MustParseApiVersion(s string) ApiVersion {
+	v, err := ParseApiVersion(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// IsPreview reports whether the version has a pre-release suffix.
+func (v ApiVersion) IsPreview() bool {
+	return v.Suffix != ""
+}
+
+// Compare returns -1, 0 or +1 depending on whether v is earlier than, the same as, or later than other.
+// Versions are ordered by date, and on the same date pre-release versions are earlier than the stable version.
+func (v ApiVersion) Compare(other ApiVersion) int {
+	for _, d := range []int{
+		v.Year - other.Year,
+		v.Month - other.Month,
+		v.Day - other.Day,
+		apiVersionSuffixes[v.Suffix] - apiVersionSuffixes[other.Suffix],
+	} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+	}
+	return 0
+}
+
+func (v ApiVersion) String() string {
+	s := fmt.Sprintf("%04d-%02d-%02d", v.Year, v.Month, v.Day)
+	if v.Suffix != "" {
+		s += "-" + v.Suffix
+	}
+	return s
+}
+
+// PreviewPolicy controls how an AzApiRule treats resources that use a pre-release API version.
+type PreviewPolicy int
+
+const (
+	PreviewAllow PreviewPolicy = iota // Pre-release versions are checked like any other version.
+	PreviewSkip                       // Resources with pre-release versions are not checked.
+	PreviewDeny                       // An issue is raised for resources with pre-release versions.
+)
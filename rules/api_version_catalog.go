@@ -0,0 +1,75 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// ApiVersionCatalog lists the available API versions of each Azure resource type.
+// It is loaded offline, e.g. from a file exported from the azure-rest-api-specs or bicep-types-az repositories.
+type ApiVersionCatalog struct {
+	versions map[string][]ApiVersion // Keyed by the lower case resource type, sorted from earliest to latest.
+}
+
+// NewApiVersionCatalog creates a catalog from a map of resource type to API versions,
+// e.g. `{"Microsoft.Storage/storageAccounts": ["2023-01-01", "2023-05-01"]}`.
+func NewApiVersionCatalog(versions map[string][]string) (*ApiVersionCatalog, error) {
+	c := &ApiVersionCatalog{versions: make(map[string][]ApiVersion, len(versions))}
+	for resourceType, vs := range versions {
+		parsed := make([]ApiVersion, 0, len(vs))
+		for _, s := range vs {
+			v, err := ParseApiVersion(s)
+			if err != nil {
+				return nil, fmt.Errorf("resource type %s: %w", resourceType, err)
+			}
+			parsed = append(parsed, v)
+		}
+		sort.Slice(parsed, func(i, j int) bool {
+			return parsed[i].Compare(parsed[j]) < 0
+		})
+		key := strings.ToLower(resourceType)
+		c.versions[key] = append(c.versions[key], parsed...)
+	}
+	return c, nil
+}
+
+// LoadApiVersionCatalog reads a catalog from a JSON file in the filesystem, which may be an embed.FS or os.DirFS.
+// The file is an object of resource type to a list of API versions, see NewApiVersionCatalog.
+func LoadApiVersionCatalog(fsys fs.FS, path string) (*ApiVersionCatalog, error) {
+	src, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read API version catalog: %w", err)
+	}
+	var versions map[string][]string
+	if err := json.Unmarshal(src, &versions); err != nil {
+		return nil, fmt.Errorf("could not parse API version catalog %s: %w", path, err)
+	}
+	c, err := NewApiVersionCatalog(versions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API version catalog %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Versions returns the API versions of the resource type, from earliest to latest.
+// The resource type is matched case-insensitively.
+func (c *ApiVersionCatalog) Versions(resourceType string) []ApiVersion {
+	return c.versions[strings.ToLower(resourceType)]
+}
+
+// LatestStable returns the newest API version of the resource type without a pre-release suffix.
+func (c *ApiVersionCatalog) LatestStable(resourceType string) (ApiVersion, bool) {
+	vs := c.Versions(resourceType)
+	for i := len(vs) - 1; i >= 0; i-- {
+		if !vs[i].IsPreview() {
+			return vs[i], true
+		}
+	}
+	return ApiVersion{}, false
+}
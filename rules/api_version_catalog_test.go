@@ -0,0 +1,46 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadApiVersionCatalog(t *testing.T) {
+	fsys := fstest.MapFS{
+		"catalog.json": {Data: []byte(`{
+  "Microsoft.Storage/storageAccounts": ["2023-05-01", "2022-09-01", "2024-01-01-preview", "2023-01-01"],
+  "Microsoft.Test/previewOnly": ["2024-01-01-preview"]
+}`)},
+		"invalid.json": {Data: []byte(`{"Microsoft.Storage/storageAccounts": ["latest"]}`)},
+		"broken.json":  {Data: []byte(`{"Microsoft.Storage/storageAccounts": `)},
+	}
+	c, err := LoadApiVersionCatalog(fsys, "catalog.json")
+	require.NoError(t, err)
+	require.Equal(t, []ApiVersion{
+		MustParseApiVersion("2022-09-01"),
+		MustParseApiVersion("2023-01-01"),
+		MustParseApiVersion("2023-05-01"),
+		MustParseApiVersion("2024-01-01-preview"),
+	}, c.Versions("microsoft.storage/storageaccounts"))
+
+	latest, ok := c.LatestStable("Microsoft.Storage/storageAccounts")
+	require.True(t, ok)
+	require.Equal(t, "2023-05-01", latest.String())
+
+	_, ok = c.LatestStable("Microsoft.Test/previewOnly")
+	require.False(t, ok)
+	_, ok = c.LatestStable("Microsoft.Test/unknown")
+	require.False(t, ok)
+
+	_, err = LoadApiVersionCatalog(fsys, "invalid.json")
+	require.ErrorContains(t, err, "Microsoft.Storage/storageAccounts")
+	_, err = LoadApiVersionCatalog(fsys, "broken.json")
+	require.Error(t, err)
+	_, err = LoadApiVersionCatalog(fsys, "missing.json")
+	require.Error(t, err)
+}
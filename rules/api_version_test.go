@@ -0,0 +1,122 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseApiVersion(t *testing.T) {
+	testCases := []struct {
+		in        string
+		expected  ApiVersion
+		preview   bool
+		expectErr bool
+	}{
+		{in: "2023-05-01", expected: ApiVersion{Year: 2023, Month: 5, Day: 1}},
+		{in: "2023-05-01-preview", expected: ApiVersion{Year: 2023, Month: 5, Day: 1, Suffix: "preview"}, preview: true},
+		{in: "2023-05-01-Preview", expected: ApiVersion{Year: 2023, Month: 5, Day: 1, Suffix: "preview"}, preview: true},
+		{in: "2023-05-01-beta", expected: ApiVersion{Year: 2023, Month: 5, Day: 1, Suffix: "beta"}, preview: true},
+		{in: "2023-05-01-privatepreview", expected: ApiVersion{Year: 2023, Month: 5, Day: 1, Suffix: "privatepreview"}, preview: true},
+		{in: "2023-05-01-alpha", expectErr: true},
+		{in: "2023-05", expectErr: true},
+		{in: "2023-13-01", expectErr: true},
+		{in: "2023-05-45", expectErr: true},
+		{in: "2023-02-30", expectErr: true},
+		{in: "2024-02-29", expected: ApiVersion{Year: 2024, Month: 2, Day: 29}},
+		{in: "latest", expectErr: true},
+		{in: "", expectErr: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.in, func(t *testing.T) {
+			got, err := ParseApiVersion(tC.in)
+			if tC.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tC.expected, got)
+			require.Equal(t, tC.preview, got.IsPreview())
+		})
+	}
+}
+
+func TestApiVersionCompare(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "2023-05-01", b: "2023-05-01", expected: 0},
+		{a: "2023-05-01", b: "2023-06-01", expected: -1},
+		{a: "2024-01-01", b: "2023-12-31", expected: 1},
+		{a: "2023-05-01-preview", b: "2023-05-01", expected: -1},
+		{a: "2023-05-01", b: "2023-05-01-preview", expected: 1},
+		{a: "2023-05-01-privatepreview", b: "2023-05-01-beta", expected: -1},
+		{a: "2023-05-01-beta", b: "2023-05-01-preview", expected: -1},
+		{a: "2023-05-02-preview", b: "2023-05-01", expected: 1},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.a+" "+tC.b, func(t *testing.T) {
+			require.Equal(t, tC.expected, MustParseApiVersion(tC.a).Compare(MustParseApiVersion(tC.b)))
+		})
+	}
+}
+
+func TestCheckAzApiType(t *testing.T) {
+	minimum := newApiVersionBound("2023-05-01")
+	maximum := newApiVersionBound("2024-01-01")
+	testCases := []struct {
+		desc      string
+		gotType   string
+		ok        bool
+		expectErr bool
+	}{
+		{desc: "in range", gotType: "Microsoft.Test/things@2023-06-01", ok: true},
+		{desc: "case insensitive type", gotType: "microsoft.test/Things@2023-06-01", ok: true},
+		{desc: "minimum", gotType: "Microsoft.Test/things@2023-05-01", ok: true},
+		{desc: "preview before minimum", gotType: "Microsoft.Test/things@2023-05-01-preview"},
+		{desc: "preview after minimum", gotType: "Microsoft.Test/things@2023-05-02-preview", ok: true},
+		{desc: "after maximum", gotType: "Microsoft.Test/things@2024-02-01"},
+		{desc: "other type", gotType: "Microsoft.Test/others@2023-06-01"},
+		{desc: "other type with invalid version", gotType: "Microsoft.Test/others@latest"},
+		{desc: "invalid version", gotType: "Microsoft.Test/things@latest", expectErr: true},
+		{desc: "missing version", gotType: "Microsoft.Test/things", expectErr: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, ok, err := checkAzApiType(tC.gotType, "Microsoft.Test/things", minimum, maximum)
+			if tC.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tC.ok, ok)
+		})
+	}
+}
+
+func TestCheckAzApiTypeUnparsedBounds(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		minimum string
+		maximum string
+		gotType string
+		ok      bool
+	}{
+		{desc: "no bounds", gotType: "Microsoft.Test/things@2023-06-01", ok: true},
+		{desc: "after short minimum", minimum: "2023-05", gotType: "Microsoft.Test/things@2023-05-01", ok: true},
+		{desc: "before short minimum", minimum: "2023-05", gotType: "Microsoft.Test/things@2023-04-30"},
+		{desc: "before short maximum", maximum: "2023-05", gotType: "Microsoft.Test/things@2023-04-30", ok: true},
+		{desc: "after short maximum", maximum: "2023-05", gotType: "Microsoft.Test/things@2023-05-01"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, ok, err := checkAzApiType(tC.gotType, "Microsoft.Test/things", newApiVersionBound(tC.minimum), newApiVersionBound(tC.maximum))
+			require.NoError(t, err)
+			require.Equal(t, tC.ok, ok)
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Azure/tflint-helper/blockquery"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/terraform-linters/tflint-plugin-sdk/hclext"
+	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// UnknownPolicy controls how a rule treats query results that are not known until apply,
+// e.g. values that refer to the outputs of other resources.
+// It applies to partially unknown values too, such as an object with one unknown attribute.
+type UnknownPolicy int
+
+const (
+	UnknownCompare UnknownPolicy = iota // Unknown values are passed to the compare function, which usually fails them.
+	UnknownIgnore                       // Unknown values are not checked.
+	UnknownWarn                         // A warning is raised for unknown values, whatever the severity of the rule.
+	UnknownFail                         // An issue is raised for unknown values.
+)
+
+// attributeQuery is the query that a rule runs against the value of an attribute, and how the result is compared.
+type attributeQuery struct {
+	compiledQuery    *blockquery.CompiledQuery
+	expected         []cty.Value
+	mustExist        bool
+	compareEachMatch bool
+	unknownPolicy    UnknownPolicy
+}
+
+// check runs the query against the evaluated attribute value and raises an issue on behalf of the rule for each failure.
+func (q *attributeQuery) check(runner tflint.Runner, rule tflint.Rule, cmpFn blockquery.ResultCompareFunc, val cty.Value, attr *hclext.Attribute) error {
+	if q.compareEachMatch {
+		return q.compareMatches(runner, rule, cmpFn, val, attr)
+	}
+	qr, err := q.compiledQuery.Eval(val)
+	if err != nil {
+		return q.handleQueryError(runner, rule, err, attr)
+	}
+	if q.handleUnknown(runner, rule, qr, nil, func() hcl.Range { return q.issueRange(val, attr) }) {
+		return nil
+	}
+	ok, msg, err := cmpFn(qr, q.expected...)
+	if err != nil {
+		return fmt.Errorf("could not compare values: %w", err)
+	}
+	if !ok {
+		runner.EmitIssue( // nolint: errcheck
+			rule,
+			msg,
+			q.issueRange(val, attr),
+		)
+	}
+	return nil
+}
+
+// handleQueryError raises an issue if the query found nothing and a result must exist, other errors are returned.
+func (q *attributeQuery) handleQueryError(runner tflint.Runner, rule tflint.Rule, err error, attr *hclext.Attribute) error {
+	notExistsErr := &blockquery.QueryErrorNotFound{Query: q.compiledQuery.String()}
+	if !errors.As(err, &notExistsErr) {
+		return fmt.Errorf("could not query value: %w", err)
+	}
+	if q.mustExist {
+		runner.EmitIssue( // nolint: errcheck
+			rule,
+			err.Error(),
+			attr.Range,
+		)
+	}
+	return nil
+}
+
+// issueRange returns the source range of the part of the attribute found by the query.
+// If the query has several matches, the range of the deepest item containing all of them is returned.
+func (q *attributeQuery) issueRange(val cty.Value, attr *hclext.Attribute) hcl.Range {
+	matches, err := q.compiledQuery.EvalMatches(val)
+	if err != nil {
+		return attr.Range
+	}
+	return rangeForMatches(attr, matches)
+}
+
+// compareMatches runs the compare function against each value found by the query and raises an issue for each failure.
+func (q *attributeQuery) compareMatches(runner tflint.Runner, rule tflint.Rule, cmpFn blockquery.ResultCompareFunc, val cty.Value, attr *hclext.Attribute) error {
+	matches, err := q.compiledQuery.EvalMatches(val)
+	if err != nil {
+		return q.handleQueryError(runner, rule, err, attr)
+	}
+	known := make([]blockquery.Match, 0, len(matches))
+	for _, m := range matches {
+		if !q.handleUnknown(runner, rule, m.Value, m.Path, func() hcl.Range { return rangeForPath(attr, m.Path) }) {
+			known = append(known, m)
+		}
+	}
+	failures, err := blockquery.CompareEachMatch(known, cmpFn, q.expected...)
+	if err != nil {
+		return fmt.Errorf("could not compare values: %w", err)
+	}
+	for _, f := range failures {
+		runner.EmitIssue( // nolint: errcheck
+			rule,
+			f.Message,
+			rangeForPath(attr, f.Path),
+		)
+	}
+	return nil
+}
+
+// handleUnknown applies the unknown policy to a value that is not wholly known, and reports whether the value has been dealt with.
+// If it returns false the value must be compared as usual.
+// The range of the issue is only computed if an issue is raised.
+func (q *attributeQuery) handleUnknown(runner tflint.Runner, rule tflint.Rule, val cty.Value, path cty.Path, rng func() hcl.Range) bool {
+	if val.IsWhollyKnown() || q.unknownPolicy == UnknownCompare {
+		return false
+	}
+	unknown := "unknown"
+	if val.IsKnown() {
+		unknown = "partially unknown"
+	}
+	var msg string
+	switch q.unknownPolicy {
+	case UnknownIgnore:
+		return true
+	case UnknownWarn:
+		if sr, ok := rule.(severityRule); ok {
+			rule = sr.withIssueSeverity(tflint.WARNING)
+		}
+		msg = fmt.Sprintf("returned value is %s, so it could not be checked", unknown)
+	case UnknownFail:
+		msg = fmt.Sprintf("returned value is %s, which is not allowed", unknown)
+	}
+	if len(path) > 0 {
+		msg = fmt.Sprintf("`%s`: %s", blockquery.FormatPath(path), msg)
+	}
+	runner.EmitIssue( // nolint: errcheck
+		rule,
+		msg,
+		rng(),
+	)
+	return true
+}
+
+// severityRule is a rule that can raise issues with a different severity.
+// The returned rule must be of the same type, so that issues are still attributed to the rule.
+type severityRule interface {
+	withIssueSeverity(severity tflint.Severity) tflint.Rule
+}
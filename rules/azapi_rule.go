@@ -4,38 +4,51 @@
 package rules
 
 import (
-	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/Azure/tflint-helper/blockquery"
 	"github.com/Azure/tflint-helper/modulecontent"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/terraform-linters/tflint-plugin-sdk/hclext"
 	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
 	"github.com/zclconf/go-cty/cty"
 )
 
 // AzApiRule runs the specified gjson query on the `body` attribute of `azapi_resource` resources and checks if the result is as expected.
+// Use WithBlockLabels to also check other azapi blocks with a `type` and `body`, such as `azapi_update_resource`.
 type AzApiRule struct {
 	tflint.DefaultRule // Embed the default rule to reuse its implementation
 	blockquery.BlockQuery
-	expected          []cty.Value
-	maximumApiVersion string
-	minimumApiVersion string
+	attributeQuery
+	maximumApiVersion apiVersionBound
+	minimumApiVersion apiVersionBound
+	previewPolicy     PreviewPolicy
 	link              string
 	resourceType      string
+	severity          tflint.Severity
 	ruleName          string
-	mustExist         bool
+	blockLabels       []string
+	blockTypes        []string
+	labelMustExist    map[string]bool
+	bodyMustExistFor  map[string]bool
+	fetchOptions      []modulecontent.FetchOption
 }
 
 var _ tflint.Rule = &AzApiRule{}
+var _ severityRule = &AzApiRule{}
 var _ modulecontent.BlockFetcher = &AzApiRule{}
+var _ modulecontent.LabelOnesFetcher = &AzApiRule{}
+var _ modulecontent.BlockTypesFetcher = &AzApiRule{}
 
 // AzApiRule creates a rule to check the `body` attribute of `azapi_resource` resources.
 // The `query` parameter is a gjson query string to run against the `body` attribute.
 // The `compareFunc` parameter is a function to compare the result of the query with the expected results. E.g. `blockquery.IsOneOf`.
 // The `expectedResults` parameter is a list of expected results, use the `blockquery.New*Results` functions to create them.
 // The resource type is the first part of the `type` attribute of the resource, e.g. "Microsoft.Compute/virtualMachines" for VMs.
-// Use the `minimumApiVersion` and `maximumApiVersion` parameters to filter resources based on their API version.
+// Use the `minimumApiVersion` and `maximumApiVersion` parameters to filter resources based on their API version, or leave them empty for no bound.
+// The query is parsed when the rule is created, this function panics if it is malformed.
+// API version bounds that cannot be parsed, e.g. `2023-05`, are compared with the version as strings.
 func NewAzApiRuleQueryMustExist(
 	ruleName, link, resourceType, minimumApiVersion, maximumApiVersion, query string,
 	compareFunc blockquery.ResultCompareFunc,
@@ -50,16 +63,23 @@ func NewAzApiRuleQueryMustExist(
 			query,
 			compareFunc,
 		),
-		expected:          expectedResults,
+		attributeQuery: attributeQuery{
+			compiledQuery: blockquery.MustCompile(query),
+			expected:      expectedResults,
+			mustExist:     true,
+		},
 		link:              link,
-		maximumApiVersion: maximumApiVersion,
-		minimumApiVersion: minimumApiVersion,
+		maximumApiVersion: newApiVersionBound(maximumApiVersion),
+		minimumApiVersion: newApiVersionBound(minimumApiVersion),
 		resourceType:      resourceType,
 		ruleName:          ruleName,
-		mustExist:         true,
+		severity:          tflint.ERROR,
+		blockLabels:       []string{"azapi_resource"},
+		blockTypes:        []string{"resource"},
 	}
 }
 
+// NewAzApiRuleQueryOptionalExist is like NewAzApiRuleQueryMustExist, but does not raise an issue if the query returns no result.
 func NewAzApiRuleQueryOptionalExist(
 	ruleName, link, resourceType, minimumApiVersion, maximumApiVersion, query string,
 	compareFunc blockquery.ResultCompareFunc,
@@ -74,16 +94,115 @@ func NewAzApiRuleQueryOptionalExist(
 			query,
 			compareFunc,
 		),
-		expected:          expectedResults,
+		attributeQuery: attributeQuery{
+			compiledQuery: blockquery.MustCompile(query),
+			expected:      expectedResults,
+			mustExist:     false,
+		},
 		link:              link,
-		maximumApiVersion: maximumApiVersion,
-		minimumApiVersion: minimumApiVersion,
+		maximumApiVersion: newApiVersionBound(maximumApiVersion),
+		minimumApiVersion: newApiVersionBound(minimumApiVersion),
 		resourceType:      resourceType,
 		ruleName:          ruleName,
-		mustExist:         false,
+		severity:          tflint.ERROR,
+		blockLabels:       []string{"azapi_resource"},
+		blockTypes:        []string{"resource"},
 	}
 }
 
+// WithCompareEachMatch makes the rule run the compare function against each value found by the query,
+// rather than against the combined result of any wildcards or filters.
+// An issue is raised for each offending value, naming its path in the body, e.g. `properties.subnets[2].name`.
+func (r *AzApiRule) WithCompareEachMatch() *AzApiRule {
+	r.compareEachMatch = true
+	return r
+}
+
+// WithUnknownPolicy sets how values that are not known until apply are treated, the default is UnknownCompare.
+// E.g. use UnknownIgnore to skip values that refer to the outputs of other resources, or UnknownWarn to flag them as warnings.
+func (r *AzApiRule) WithUnknownPolicy(policy UnknownPolicy) *AzApiRule {
+	r.unknownPolicy = policy
+	return r
+}
+
+// WithSeverity sets the severity of the issues raised by the rule, the default is tflint.ERROR.
+func (r *AzApiRule) WithSeverity(severity tflint.Severity) *AzApiRule {
+	r.severity = severity
+	return r
+}
+
+// WithBlockLabels sets the azapi block types that the rule checks, the default is `azapi_resource`.
+// E.g. use WithBlockLabels("azapi_resource", "azapi_update_resource", "azapi_resource_action").
+// When more than one block type is checked, issue messages are prefixed with the block type that raised them.
+// This function panics if no labels are given.
+func (r *AzApiRule) WithBlockLabels(labels ...string) *AzApiRule {
+	if len(labels) == 0 {
+		panic("WithBlockLabels requires at least one label")
+	}
+	r.blockLabels = labels
+	return r
+}
+
+// WithBlockTypes sets the types of block that the rule checks, the default is `resource`.
+// E.g. use WithBlockTypes("resource", "data", "ephemeral") together with WithBlockLabels to govern the API versions of every azapi block.
+// When more than one block type is checked, issue messages are prefixed with the block type, e.g. `data.azapi_resource`.
+// This function panics if no block types are given.
+func (r *AzApiRule) WithBlockTypes(blockTypes ...string) *AzApiRule {
+	if len(blockTypes) == 0 {
+		panic("WithBlockTypes requires at least one block type")
+	}
+	r.blockTypes = blockTypes
+	return r
+}
+
+// WithPreviewPolicy sets how resources with a pre-release API version, e.g. `2023-05-01-preview`, are treated.
+// The default is PreviewAllow, use PreviewDeny to ban pre-release versions or PreviewSkip to ignore them.
+func (r *AzApiRule) WithPreviewPolicy(policy PreviewPolicy) *AzApiRule {
+	r.previewPolicy = policy
+	return r
+}
+
+// WithChildModules makes the rule also check the resources of local child modules, e.g. `./modules/network`.
+// Each child module is evaluated with the inputs of its `module` block, and issue messages are prefixed with its address, e.g. `module.network`.
+func (r *AzApiRule) WithChildModules() *AzApiRule {
+	r.fetchOptions = append(r.fetchOptions, modulecontent.WithChildModules())
+	return r
+}
+
+// WithFetchOptions sets how the resources are fetched, e.g. `modulecontent.WithVarFiles("prod.tfvars")` to evaluate them with the values of a variables file.
+// When the body refers to input variables that are not set by their default, issue messages name the source of each, e.g. `(var.sku from prod.tfvars:2)`.
+func (r *AzApiRule) WithFetchOptions(opts ...modulecontent.FetchOption) *AzApiRule {
+	r.fetchOptions = append(r.fetchOptions, opts...)
+	return r
+}
+
+// WithLabelMustExist overrides whether the query must return a result for blocks of the given type.
+// This is useful for `azapi_update_resource`, which usually carries a partial body.
+func (r *AzApiRule) WithLabelMustExist(label string, mustExist bool) *AzApiRule {
+	if r.labelMustExist == nil {
+		r.labelMustExist = make(map[string]bool)
+	}
+	r.labelMustExist[label] = mustExist
+	return r
+}
+
+// WithBodyMustExist overrides whether blocks of the given block type, e.g. `data`, must have a `body` attribute.
+// By default `resource` blocks must have one and other block types need not, as e.g. `data "azapi_resource"` has no `body` argument.
+func (r *AzApiRule) WithBodyMustExist(blockType string, mustExist bool) *AzApiRule {
+	if r.bodyMustExistFor == nil {
+		r.bodyMustExistFor = make(map[string]bool)
+	}
+	r.bodyMustExistFor[blockType] = mustExist
+	return r
+}
+
+// withIssueSeverity returns a copy of the rule that raises issues with the severity.
+func (r *AzApiRule) withIssueSeverity(severity tflint.Severity) tflint.Rule {
+	c := *r
+	c.severity = severity
+	return &c
+}
+
 func (r *AzApiRule) Link() string {
 	return r.link
 }
@@ -93,7 +212,7 @@ func (r *AzApiRule) Enabled() bool {
 }
 
 func (r *AzApiRule) Severity() tflint.Severity {
-	return tflint.ERROR
+	return r.severity
 }
 
 func (r *AzApiRule) Name() string {
@@ -101,7 +220,11 @@ func (r *AzApiRule) Name() string {
 }
 
 func (r *AzApiRule) LabelOne() string {
-	return "azapi_resource"
+	return r.blockLabels[0]
+}
+
+func (r *AzApiRule) LabelOnes() []string {
+	return r.blockLabels
 }
 
 func (r *AzApiRule) LabelNames() []string {
@@ -109,7 +232,11 @@ func (r *AzApiRule) LabelNames() []string {
 }
 
 func (r *AzApiRule) BlockType() string {
-	return "resource"
+	return r.blockTypes[0]
+}
+
+func (r *AzApiRule) BlockTypes() []string {
+	return r.blockTypes
 }
 
 func (r *AzApiRule) Attributes() []string {
@@ -121,11 +248,13 @@ func (r *AzApiRule) Check(runner tflint.Runner) error {
 }
 
 func (r *AzApiRule) queryResource(runner tflint.Runner, ct cty.Type) error {
-	ctx, resources, diags := modulecontent.FetchBlocks(r, runner)
+	resources, diags := modulecontent.FetchModuleBlocks(r, runner, r.fetchOptions...)
 	if diags.HasErrors() {
 		return fmt.Errorf("could not get partial content: %s", diags)
 	}
 	for _, resource := range resources {
+		ctx := resource.Evaluator
+		runner := r.blockRunner(runner, resource)
 		typeAttr, typeAttrExists := resource.Body.Attributes["type"]
 		if !typeAttrExists {
 			runner.EmitIssue( // nolint: errcheck
@@ -139,70 +268,187 @@ func (r *AzApiRule) queryResource(runner tflint.Runner, ct cty.Type) error {
 		if diags.HasErrors() {
 			return fmt.Errorf("could not evaluate type expression: %s", diags)
 		}
-		typeStr := typeVal.AsString()
-		if !checkAzApiType(typeStr, r.resourceType, r.minimumApiVersion, r.maximumApiVersion) {
+		if !typeVal.IsKnown() || typeVal.IsNull() {
 			continue
 		}
-		bodyAttr, bodyAttrExists := resource.Body.Attributes["body"]
-		if !bodyAttrExists {
+		version, ok, err := checkAzApiType(typeVal.AsString(), r.resourceType, r.minimumApiVersion, r.maximumApiVersion)
+		if err != nil {
 			runner.EmitIssue( // nolint: errcheck
 				r,
-				"Resource does not have a `body` attribute",
-				resource.DefRange,
+				err.Error(),
+				typeAttr.Expr.Range(),
 			)
 			continue
 		}
-		val, diags := ctx.EvaluateExpr(bodyAttr.Expr, ct)
-		if diags.HasErrors() {
-			return fmt.Errorf("could not evaluate body expression: %s", diags)
+		if !ok {
+			continue
 		}
-		qr, err := blockquery.QueryCty(val, r.Query)
-		if err != nil {
-			notExistsErr := &blockquery.QueryErrorNotFound{Query: r.Query}
-			if errors.As(err, &notExistsErr) {
-				if r.mustExist {
-					runner.EmitIssue( // nolint: errcheck
-						r,
-						err.Error(),
-						bodyAttr.Range,
-					)
-				}
+		if version.IsPreview() {
+			switch r.previewPolicy {
+			case PreviewSkip:
 				continue
+			case PreviewDeny:
+				runner.EmitIssue( // nolint: errcheck
+					r,
+					fmt.Sprintf("API version `%s` is a pre-release version", version),
+					typeAttr.Expr.Range(),
+				)
 			}
-			return fmt.Errorf("could not query value: %w", err)
 		}
-		ok, msg, err := r.CompareFunc(qr, r.expected...)
-		if err != nil {
-			return fmt.Errorf("could not compare values: %w", err)
+		q := r.attributeQuery
+		if mustExist, ok := r.labelMustExist[resource.Labels[0]]; ok {
+			q.mustExist = mustExist
 		}
-		if !ok {
-			runner.EmitIssue( // nolint: errcheck
-				r,
-				msg,
-				bodyAttr.Range,
-			)
+		bodyAttr, bodyAttrExists := resource.Body.Attributes["body"]
+		if !bodyAttrExists {
+			if r.bodyMustExist(resource.Block) {
+				runner.EmitIssue( // nolint: errcheck
+					r,
+					"Resource does not have a `body` attribute",
+					resource.DefRange,
+				)
+			}
+			continue
+		}
+		note := variableSourcesNote(resource, bodyAttr.Expr)
+		val, diags := ctx.EvaluateExpr(bodyAttr.Expr, ct)
+		if diags.HasErrors() {
+			return fmt.Errorf("could not evaluate body expression%s: %s", note, diags)
+		}
+		if note != "" {
+			runner = &suffixRunner{Runner: runner, suffix: note}
+		}
+		if err := q.check(runner, r, r.CompareFunc, val, bodyAttr); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func checkAzApiType(gotType, wantType, minimumApiVersion, maximumApiVersion string) bool {
-	gotSplit := strings.Split(gotType, "@")
-	if len(gotSplit) != 2 {
-		return false
+// bodyMustExist checks if an issue is raised when the block has no `body` attribute.
+// Resources must have a body and other block types need not, unless WithBodyMustExist has changed their block type.
+func (r *AzApiRule) bodyMustExist(block *hclext.Block) bool {
+	if mustExist, ok := r.bodyMustExistFor[block.Type]; ok {
+		return mustExist
 	}
-	if !strings.EqualFold(gotSplit[0], wantType) {
-		return false
+	return block.Type == "resource"
+}
+
+// blockRunner returns the runner to raise issues for the block.
+// If the rule checks more than one kind of block, messages are prefixed with the kind, e.g. `azapi_update_resource` or `data.azapi_resource`.
+// Messages for blocks in child modules are prefixed with the module address, e.g. `module.network.azapi_resource`.
+// Messages for instances of blocks with `count` or `for_each` are prefixed with the instance address, e.g. `azapi_resource.test["a"]`.
+func (r *AzApiRule) blockRunner(runner tflint.Runner, block *modulecontent.ModuleBlock) tflint.Runner {
+	if block.InstanceKey != cty.NilVal {
+		return &prefixRunner{Runner: runner, prefix: block.Address()}
 	}
-	if minimumApiVersion != "" {
-		if gotSplit[1] < minimumApiVersion {
-			return false
-		}
+	prefix := len(r.blockLabels) > 1 || len(r.blockTypes) > 1
+	if block.ModuleAddress == "" {
+		return blockKindRunner(runner, block.Block, prefix)
 	}
-	if maximumApiVersion != "" {
-		if gotSplit[1] > maximumApiVersion {
-			return false
+	return &prefixRunner{Runner: runner, prefix: block.ModuleAddress + "." + blockKind(block.Block)}
+}
+
+// blockKindRunner returns a runner that prefixes issue messages with the kind of the block if required.
+func blockKindRunner(runner tflint.Runner, block *hclext.Block, prefix bool) tflint.Runner {
+	if !prefix {
+		return runner
+	}
+	return &prefixRunner{Runner: runner, prefix: blockKind(block)}
+}
+
+// blockKind returns the kind of the block, its first label for resources, e.g. `azapi_resource`, or its type and first label, e.g. `data.azapi_resource`.
+func blockKind(block *hclext.Block) string {
+	if block.Type == "resource" {
+		return block.Labels[0]
+	}
+	return block.Type + "." + block.Labels[0]
+}
+
+// prefixRunner is a runner that prefixes the message of each issue.
+type prefixRunner struct {
+	tflint.Runner
+	prefix string
+}
+
+func (p *prefixRunner) EmitIssue(rule tflint.Rule, message string, issueRange hcl.Range) error {
+	return p.Runner.EmitIssue(rule, fmt.Sprintf("%s: %s", p.prefix, message), issueRange)
+}
+
+// suffixRunner is a runner that appends a suffix to the message of each issue.
+type suffixRunner struct {
+	tflint.Runner
+	suffix string
+}
+
+func (s *suffixRunner) EmitIssue(rule tflint.Rule, message string, issueRange hcl.Range) error {
+	return s.Runner.EmitIssue(rule, message+s.suffix, issueRange)
+}
+
+// variableSourcesNote returns a note naming the source of each input variable referred to by the expression of the block that is not set by its default,
+// e.g. ` (var.sku from prod.tfvars:2)`, or an empty string if there are none. Variables referred to through locals are included.
+func variableSourcesNote(block *modulecontent.ModuleBlock, expr hcl.Expression) string {
+	var notes []string
+	for _, name := range block.ReferencedVariables(expr) {
+		source, ok := block.VariableSources[name]
+		if !ok || source.Kind == modulecontent.VariableSourceDefault {
+			continue
 		}
+		notes = append(notes, fmt.Sprintf("var.%s from %s", name, source))
 	}
-	return true
+	if len(notes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", strings.Join(notes, ", "))
+}
+
+// checkAzApiType checks if the azapi type, e.g. `Microsoft.Network/publicIPAddresses@2023-05-01`, is the wanted resource type
+// with an API version within the bounds.
+// An error is returned if the resource type is wanted but the API version is missing or invalid.
+func checkAzApiType(gotType, wantType string, minimumApiVersion, maximumApiVersion apiVersionBound) (ApiVersion, bool, error) {
+	resourceType, versionStr, found := strings.Cut(gotType, "@")
+	if !strings.EqualFold(resourceType, wantType) {
+		return ApiVersion{}, false, nil
+	}
+	if !found {
+		return ApiVersion{}, false, fmt.Errorf("type `%s` does not have an API version", gotType)
+	}
+	version, err := ParseApiVersion(versionStr)
+	if err != nil {
+		return ApiVersion{}, false, err
+	}
+	if minimumApiVersion.compare(version, versionStr) < 0 {
+		return version, false, nil
+	}
+	if maximumApiVersion.compare(version, versionStr) > 0 {
+		return version, false, nil
+	}
+	return version, true, nil
+}
+
+// apiVersionBound is a minimum or maximum API version, the zero value is no bound.
+type apiVersionBound struct {
+	raw     string
+	version *ApiVersion // The parsed bound, or nil if it is not a valid API version.
+}
+
+// newApiVersionBound parses an API version bound, an empty string is no bound.
+func newApiVersionBound(s string) apiVersionBound {
+	b := apiVersionBound{raw: s}
+	if v, err := ParseApiVersion(s); err == nil {
+		b.version = &v
+	}
+	return b
+}
+
+// compare returns -1, 0 or +1 depending on whether the version is earlier than, the same as, or later than the bound, or 0 if there is no bound.
+// If the bound is not a valid API version, the version string is compared with it as a string.
+func (b apiVersionBound) compare(version ApiVersion, versionStr string) int {
+	switch {
+	case b.raw == "":
+		return 0
+	case b.version != nil:
+		return version.Compare(*b.version)
+	}
+	return strings.Compare(versionStr, b.raw)
 }
@@ -9,8 +9,11 @@ import (
 
 	"github.com/Azure/tflint-helper/blockquery"
 	"github.com/Azure/tflint-helper/modulecontent"
+	"github.com/hashicorp/hcl/v2"
 	"github.com/prashantv/gostub"
 	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/terraform-linters/tflint-plugin-sdk/helper"
 	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
 	"github.com/zclconf/go-cty/cty"
@@ -28,7 +31,7 @@ func TestAzapiRule(t *testing.T) {
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsOneOf, blockquery.NewStringResults("fiz")...),
 			content: `
 		resource "azapi_resource" "test" {
-		  type = "testType@0000-00-00"
+		  type = "testType@2000-01-01"
 		  body = {
 			  foo = "fiz"
 				bar = "biz"
@@ -41,7 +44,7 @@ func TestAzapiRule(t *testing.T) {
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsOneOf, blockquery.NewStringResults("fuz", "fiz")...),
 			content: `
 		resource "azapi_resource" "test" {
-		  type = "testType@0000-00-00"
+		  type = "testType@2000-01-01"
 		  body = {
 			  foo = "fiz"
 				bar = "biz"
@@ -54,7 +57,7 @@ func TestAzapiRule(t *testing.T) {
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsOneOf, blockquery.NewStringResults("not_fiz")...),
 			content: `
 		resource "azapi_resource" "test" {
-		  type = "testType@0000-00-00"
+		  type = "testType@2000-01-01"
 		  body = {
 			  foo = "fiz"
 				bar = "biz"
@@ -72,7 +75,7 @@ func TestAzapiRule(t *testing.T) {
 			rule: NewAzApiRuleQueryOptionalExist("test", "https://example.com", "testType", "", "", "bat", blockquery.IsOneOf, blockquery.NewStringResults()...),
 			content: `
 		resource "azapi_resource" "test" {
-		  type = "testType@0000-00-00"
+		  type = "testType@2000-01-01"
 		  body = {
 			  foo = "fiz"
 				bar = "biz"
@@ -85,7 +88,7 @@ func TestAzapiRule(t *testing.T) {
 			rule: NewAzApiRuleQueryOptionalExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsNull),
 			content: `
 		resource "azapi_resource" "test" {
-		  type = "testType@0000-00-00"
+		  type = "testType@2000-01-01"
 		  body = {
 			  foo = "fiz"
 				bar = "biz"
@@ -103,7 +106,7 @@ func TestAzapiRule(t *testing.T) {
 			rule: NewAzApiRuleQueryOptionalExist("test", "https://example.com", "testType", "", "", "notExist", blockquery.IsNull),
 			content: `
 		resource "azapi_resource" "test" {
-		  type = "testType@0000-00-00"
+		  type = "testType@2000-01-01"
 		  body = {
 			  foo = "fiz"
 				bar = "biz"
@@ -116,7 +119,7 @@ func TestAzapiRule(t *testing.T) {
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsOneOf, blockquery.NewIntResults(2)...),
 			content: `
 		resource "azapi_resource" "test" {
-		  type = "testType@0000-00-00"
+		  type = "testType@2000-01-01"
 		  body = {
 			  foo = 2
 				bar = "biz"
@@ -129,7 +132,7 @@ func TestAzapiRule(t *testing.T) {
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsOneOf, blockquery.NewIntResults(0)...),
 			content: `
 		resource "azapi_resource" "test" {
-		  type = "testType@0000-00-00"
+		  type = "testType@2000-01-01"
 		  body = {
 			  foo = 2
 				bar = "biz"
@@ -147,7 +150,7 @@ func TestAzapiRule(t *testing.T) {
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsOneOf, blockquery.NewBoolResult(true)),
 			content: `
 		resource "azapi_resource" "test" {
-		  type = "testType@0000-00-00"
+		  type = "testType@2000-01-01"
 		  body = {
 			  foo = true
 				bar = "biz"
@@ -160,7 +163,7 @@ func TestAzapiRule(t *testing.T) {
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsOneOf, blockquery.NewBoolResult(true)),
 			content: `
 		resource "azapi_resource" "test" {
-		  type = "testType@0000-00-00"
+		  type = "testType@2000-01-01"
 		  body = {
 			  foo = false
 				bar = "biz"
@@ -178,7 +181,7 @@ func TestAzapiRule(t *testing.T) {
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsOneOf, blockquery.NewListResults([]cty.Value{cty.NumberIntVal(1), cty.NumberIntVal(2), cty.NumberIntVal(3)})...),
 			content: `
 		resource "azapi_resource" "test" {
-		  type = "testType@0000-00-00"
+		  type = "testType@2000-01-01"
 		  body = {
 			  foo = [1, 2, 3]
 				bar = "biz"
@@ -191,7 +194,7 @@ func TestAzapiRule(t *testing.T) {
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsOneOf, blockquery.NewListResults([]cty.Value{cty.NumberIntVal(4), cty.NumberIntVal(5), cty.NumberIntVal(6)})...),
 			content: `
 		resource "azapi_resource" "test" {
-		  type = "testType@0000-00-00"
+		  type = "testType@2000-01-01"
 		  body = {
 			  foo = [1, 2, 3]
 				bar = "biz"
@@ -209,7 +212,7 @@ func TestAzapiRule(t *testing.T) {
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo.#.bar", blockquery.EachIsOneOf, blockquery.NewListResults([]cty.Value{cty.NumberIntVal(1), cty.NumberIntVal(2), cty.NumberIntVal(3)})...),
 			content: `
 resource "azapi_resource" "test" {
-	type = "testType@0000-00-00"
+	type = "testType@2000-01-01"
 	body = {
 			foo = [
 				{
@@ -231,7 +234,7 @@ resource "azapi_resource" "test" {
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo.#.bar", blockquery.EachIsOneOf, blockquery.NewListResults([]cty.Value{cty.NumberIntVal(1), cty.NumberIntVal(2), cty.NumberIntVal(3)})...),
 			content: `
 resource "azapi_resource" "test" {
-type = "testType@0000-00-00"
+type = "testType@2000-01-01"
 body = {
 		foo = [
 			{
@@ -258,7 +261,7 @@ body = {
 			rule: NewAzApiRuleQueryOptionalExist("test", "https://example.com", "testType", "", "", "notexist", blockquery.EachIsOneOf, blockquery.NewStringResults("fiz")...),
 			content: `
 		resource "azapi_resource" "test" {
-		  type = "testType@0000-00-00"
+		  type = "testType@2000-01-01"
 		  body = {
 			  foo = "fiz"
 				bar = "biz"
@@ -271,7 +274,7 @@ body = {
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "notexist", blockquery.EachIsOneOf, blockquery.NewStringResults("fiz")...),
 			content: `
 		resource "azapi_resource" "test" {
-		  type = "testType@0000-00-00"
+		  type = "testType@2000-01-01"
 		  body = {
 			  foo = "fiz"
 				bar = "biz"
@@ -314,7 +317,7 @@ body = {
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "query", blockquery.IsNotNull),
 			content: `
 resource "azapi_resource" "test" {
-	type     = "testType@0000-00-00"
+	type     = "testType@2000-01-01"
 	not_body = {}
 }`,
 			expected: helper.Issues{
@@ -329,7 +332,7 @@ resource "azapi_resource" "test" {
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "objectarray.#.attr", blockquery.EachIsOneOf, blockquery.NewStringResults("val")...),
 			content: `
 resource "azapi_resource" "test" {
-	type     = "testType@0000-00-00"
+	type     = "testType@2000-01-01"
 	body = {
 		objectarray = [
 		  {
@@ -343,6 +346,410 @@ resource "azapi_resource" "test" {
 }`,
 			expected: helper.Issues{},
 		},
+		{
+			name: "filter query",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", `subnets.#(name=="AzureFirewallSubnet").addressPrefix`, blockquery.IsOneOf, blockquery.NewStringResults("10.0.0.0/26")...),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		subnets = [
+			{
+				name          = "default"
+				addressPrefix = "10.0.1.0/24"
+			},
+			{
+				name          = "AzureFirewallSubnet"
+				addressPrefix = "10.0.0.0/24"
+			}
+		]
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", `subnets.#(name=="AzureFirewallSubnet").addressPrefix`, blockquery.IsOneOf, blockquery.NewStringResults("10.0.0.0/26")...),
+					Message: "returned value `10.0.0.0/24` not in expected values `[10.0.0.0/26]`",
+				},
+			},
+		},
+		{
+			name: "recursive descent query",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "..publicNetworkAccess", blockquery.EachIsOneOf, blockquery.NewStringResults("Disabled")...),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		properties = {
+			publicNetworkAccess = "Disabled"
+			siteConfig = {
+				publicNetworkAccess = "Enabled"
+			}
+		}
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "..publicNetworkAccess", blockquery.EachIsOneOf, blockquery.NewStringResults("Disabled")...),
+					Message: "returned values `[Disabled Enabled]` not in expected values `[Disabled]`",
+				},
+			},
+		},
+		{
+			name: "compare each match",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "subnets.#.policy", blockquery.IsOneOf, blockquery.NewStringResults("Disabled")...).WithCompareEachMatch(),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		subnets = [
+			{
+				policy = "Disabled"
+			},
+			{
+				policy = "Enabled"
+			},
+			{
+				policy = "Enabled"
+			}
+		]
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "subnets.#.policy", blockquery.IsOneOf, blockquery.NewStringResults("Disabled")...),
+					Message: "`subnets[1].policy`: returned value `Enabled` not in expected values `[Disabled]`",
+				},
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "subnets.#.policy", blockquery.IsOneOf, blockquery.NewStringResults("Disabled")...),
+					Message: "`subnets[2].policy`: returned value `Enabled` not in expected values `[Disabled]`",
+				},
+			},
+		},
+		{
+			name: "regex",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "sku.name", blockquery.MatchesRegex(`^Standard_`)),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		sku = {
+			name = "Basic_LRS"
+		}
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "sku.name", blockquery.MatchesRegex(`^Standard_`)),
+					Message: "returned value `Basic_LRS` does not match pattern `^Standard_`",
+				},
+			},
+		},
+		{
+			name: "numeric range",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "properties.retentionDays", blockquery.InRange, blockquery.NewIntResults(7, 365)...),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		properties = {
+			retentionDays = 400
+		}
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "properties.retentionDays", blockquery.InRange, blockquery.NewIntResults(7, 365)...),
+					Message: "returned value `400` is not between `7` and `365` (inclusive)",
+				},
+			},
+		},
+		{
+			name: "each is one of with mixed-type tuple",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "zones", blockquery.EachIsOneOf, blockquery.NewStringResults("1", "2", "3")...),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		zones = ["1", 2]
+	}
+}`,
+			expected: helper.Issues{},
+		},
+		{
+			name: "each is one of with set",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "zones", blockquery.EachIsOneOf, blockquery.NewStringResults("1", "2", "3")...),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		zones = toset(["4", "1"])
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "zones", blockquery.EachIsOneOf, blockquery.NewStringResults("1", "2", "3")...),
+					Message: "returned values `[1 4]` not in expected values `[1 2 3]`",
+				},
+			},
+		},
+		{
+			name: "matches shape",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "properties", blockquery.MatchesShape, blockquery.NewShapeResults(`{"sku": {"name": "Standard"}}`)...),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		properties = {
+			sku = {
+				name = "Premium"
+				tier = "Regional"
+			}
+		}
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "properties", blockquery.MatchesShape, blockquery.NewShapeResults(`{"sku": {"name": "Standard"}}`)...),
+					Message: "returned value does not match the expected shape: `sku.name` is `Premium`, expected `Standard`",
+				},
+			},
+		},
+		{
+			name: "collection contains all",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "zones", blockquery.ContainsAll, blockquery.NewStringResults("1", "2", "3")...),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		zones = ["1", "2"]
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "zones", blockquery.ContainsAll, blockquery.NewStringResults("1", "2", "3")...),
+					Message: "returned values `[1 2]` do not contain `[3]`",
+				},
+			},
+		},
+		{
+			name: "multiple block labels",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "properties.publicNetworkAccess", blockquery.IsOneOf, blockquery.NewStringResults("Disabled")...).
+				WithBlockLabels("azapi_resource", "azapi_update_resource"),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		properties = {
+			publicNetworkAccess = "Disabled"
+		}
+	}
+}
+
+resource "azapi_update_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		properties = {
+			publicNetworkAccess = "Enabled"
+		}
+	}
+}
+
+resource "azapi_resource_action" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		properties = {
+			publicNetworkAccess = "Enabled"
+		}
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "properties.publicNetworkAccess", blockquery.IsOneOf, blockquery.NewStringResults("Disabled")...),
+					Message: "azapi_update_resource: returned value `Enabled` not in expected values `[Disabled]`",
+				},
+			},
+		},
+		{
+			name: "label must exist override",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "properties.publicNetworkAccess", blockquery.IsOneOf, blockquery.NewStringResults("Disabled")...).
+				WithBlockLabels("azapi_resource", "azapi_update_resource").
+				WithLabelMustExist("azapi_update_resource", false),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		properties = {}
+	}
+}
+
+resource "azapi_update_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		properties = {}
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "properties.publicNetworkAccess", blockquery.IsOneOf, blockquery.NewStringResults("Disabled")...),
+					Message: "azapi_resource: attribute not found: publicNetworkAccess",
+				},
+			},
+		},
+		{
+			name: "no body attribute optional",
+			rule: NewAzApiRuleQueryOptionalExist("test", "https://example.com", "testType", "", "", "query", blockquery.IsNotNull),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryOptionalExist("test", "https://example.com", "testType", "", "", "query", blockquery.IsNotNull),
+					Message: "Resource does not have a `body` attribute",
+				},
+			},
+		},
+		{
+			name: "no body attribute on data source",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "query", blockquery.IsNotNull).
+				WithBlockTypes("data"),
+			content: `
+data "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	name = "test"
+}`,
+			expected: helper.Issues{},
+		},
+		{
+			name: "no body attribute on data source required",
+			rule: NewAzApiRuleQueryOptionalExist("test", "https://example.com", "testType", "", "", "query", blockquery.IsNotNull).
+				WithBlockTypes("data").
+				WithBodyMustExist("data", true),
+			content: `
+data "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	name = "test"
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryOptionalExist("test", "https://example.com", "testType", "", "", "query", blockquery.IsNotNull),
+					Message: "Resource does not have a `body` attribute",
+				},
+			},
+		},
+		{
+			name: "resource, data and ephemeral blocks",
+			rule: NewAzApiRuleQueryOptionalExist("test", "https://example.com", "testType", "", "", "properties.publicNetworkAccess", blockquery.IsOneOf, blockquery.NewStringResults("Disabled")...).
+				WithBlockTypes("resource", "data", "ephemeral"),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		properties = {
+			publicNetworkAccess = "Enabled"
+		}
+	}
+}
+
+data "azapi_resource" "test" {
+	type      = "testType@2000-01-01"
+	parent_id = "/subscriptions/00000000-0000-0000-0000-000000000000"
+	name      = "test"
+}
+
+ephemeral "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		properties = {
+			publicNetworkAccess = "Enabled"
+		}
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryOptionalExist("test", "https://example.com", "testType", "", "", "properties.publicNetworkAccess", blockquery.IsOneOf, blockquery.NewStringResults("Disabled")...),
+					Message: "azapi_resource: returned value `Enabled` not in expected values `[Disabled]`",
+				},
+				{
+					Rule:    NewAzApiRuleQueryOptionalExist("test", "https://example.com", "testType", "", "", "properties.publicNetworkAccess", blockquery.IsOneOf, blockquery.NewStringResults("Disabled")...),
+					Message: "ephemeral.azapi_resource: returned value `Enabled` not in expected values `[Disabled]`",
+				},
+			},
+		},
+		{
+			name: "data source list",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "", blockquery.IsNotNull).
+				WithBlockTypes("data").
+				WithBlockLabels("azapi_resource_list").
+				WithPreviewPolicy(PreviewDeny),
+			content: `
+data "azapi_resource_list" "test" {
+	type      = "testType@2023-05-01-preview"
+	parent_id = "/subscriptions/00000000-0000-0000-0000-000000000000"
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "", blockquery.IsNotNull),
+					Message: "API version `2023-05-01-preview` is a pre-release version",
+				},
+			},
+		},
+		{
+			name: "preview version denied",
+			rule: NewAzApiRuleQueryOptionalExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsNotNull).
+				WithPreviewPolicy(PreviewDeny),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2023-05-01-preview"
+	body = {
+		foo = "bar"
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryOptionalExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsNotNull),
+					Message: "API version `2023-05-01-preview` is a pre-release version",
+				},
+			},
+		},
+		{
+			name: "preview version skipped",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsNotNull).
+				WithPreviewPolicy(PreviewSkip),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2023-05-01-preview"
+	body = {}
+}`,
+			expected: helper.Issues{},
+		},
+		{
+			name: "preview version before stable minimum",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "2023-05-01", "", "foo", blockquery.IsNotNull),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@2023-05-01-preview"
+	body = {}
+}`,
+			expected: helper.Issues{},
+		},
+		{
+			name: "invalid api version",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsNotNull),
+			content: `
+resource "azapi_resource" "test" {
+	type = "testType@latest"
+	body = {
+		foo = "bar"
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsNotNull),
+					Message: "invalid API version `latest`, expected the form YYYY-MM-DD with an optional suffix",
+				},
+			},
+		},
 		{
 			name: "unknown value",
 			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "key", blockquery.IsNotKnown),
@@ -352,13 +759,288 @@ variable "unknown" {
 }
 
 resource "azapi_resource" "test" {
-	type     = "testType@0000-00-00"
+	type     = "testType@2000-01-01"
 	body = {
 		key = var.unknown
 	}
 }`,
 			expected: helper.Issues{},
 		},
+		{
+			name: "for_each instances",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "sku.name", blockquery.IsOneOf, blockquery.NewStringResults("Standard")...),
+			content: `
+variable "storage_accounts" {
+	default = {
+		one = { sku = "Standard" }
+		two = { sku = "Basic" }
+	}
+}
+
+resource "azapi_resource" "test" {
+	for_each = var.storage_accounts
+	type     = "testType@2000-01-01"
+	body = {
+		sku = {
+			name = each.value.sku
+		}
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "sku.name", blockquery.IsOneOf),
+					Message: "azapi_resource.test[\"two\"]: returned value `Basic` not in expected values `[Standard]`",
+				},
+			},
+		},
+		{
+			name: "count instances",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "zone", blockquery.IsOneOf, blockquery.NewIntResults(1, 2)...),
+			content: `
+resource "azapi_resource" "test" {
+	count = 3
+	type  = "testType@2000-01-01"
+	body = {
+		zone = count.index + 1
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "zone", blockquery.IsOneOf),
+					Message: "azapi_resource.test[2]: returned value `3` not in expected values `[1 2]`",
+				},
+			},
+		},
+		{
+			name: "unknown value ignored",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "key", blockquery.IsOneOf, cty.StringVal("bar")).WithUnknownPolicy(UnknownIgnore),
+			content: `
+variable "unknown" {
+  type = string
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		sku = {
+			name = "Standard"
+			tier = var.unknown
+		}
+		key = var.unknown
+	}
+}`,
+			expected: helper.Issues{},
+		},
+		{
+			name: "unknown value compared",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "key", blockquery.IsOneOf, cty.StringVal("bar")),
+			content: `
+variable "unknown" {
+  type = string
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		sku = {
+			name = "Standard"
+			tier = var.unknown
+		}
+		key = var.unknown
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "key", blockquery.IsOneOf, cty.StringVal("bar")),
+					Message: "returned value `(unknown)` not in expected values `[bar]`",
+				},
+			},
+		},
+		{
+			name: "unknown value warning",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "key", blockquery.IsOneOf, cty.StringVal("bar")).WithUnknownPolicy(UnknownWarn),
+			content: `
+variable "unknown" {
+  type = string
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		sku = {
+			name = "Standard"
+			tier = var.unknown
+		}
+		key = var.unknown
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "key", blockquery.IsOneOf, cty.StringVal("bar")).WithSeverity(tflint.WARNING),
+					Message: "returned value is unknown, so it could not be checked",
+				},
+			},
+		},
+		{
+			name: "partially unknown value failure",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "sku", blockquery.MatchesShape, cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("Standard")})).WithUnknownPolicy(UnknownFail),
+			content: `
+variable "unknown" {
+  type = string
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		sku = {
+			name = "Standard"
+			tier = var.unknown
+		}
+		key = var.unknown
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "sku", blockquery.MatchesShape),
+					Message: "returned value is partially unknown, which is not allowed",
+				},
+			},
+		},
+		{
+			name: "unknown match failure",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "sku.*", blockquery.IsNotNull).WithCompareEachMatch().WithUnknownPolicy(UnknownFail),
+			content: `
+variable "unknown" {
+  type = string
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		sku = {
+			name = "Standard"
+			tier = var.unknown
+		}
+		key = var.unknown
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "sku.*", blockquery.IsNotNull),
+					Message: "`sku.tier`: returned value is unknown, which is not allowed",
+				},
+			},
+		},
+		{
+			name: "resource reference ignored",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "properties.sku", blockquery.IsOneOf, cty.StringVal("Standard")).WithUnknownPolicy(UnknownIgnore),
+			content: `
+resource "azapi_resource" "other" {
+	type = "otherType@2000-01-01"
+	body = {}
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		properties = azapi_resource.other.output.properties
+	}
+}`,
+			expected: helper.Issues{},
+		},
+		{
+			name: "resource reference warning",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "properties.sku", blockquery.IsOneOf, cty.StringVal("Standard")).WithUnknownPolicy(UnknownWarn),
+			content: `
+resource "azapi_resource" "other" {
+	type = "otherType@2000-01-01"
+	body = {}
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		properties = azapi_resource.other.output.properties
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "properties.sku", blockquery.IsOneOf, cty.StringVal("Standard")).WithSeverity(tflint.WARNING),
+					Message: "returned value is unknown, so it could not be checked",
+				},
+			},
+		},
+		{
+			name: "resource reference compared",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "properties.sku", blockquery.IsOneOf, cty.StringVal("Standard")),
+			content: `
+resource "azapi_resource" "other" {
+	type = "otherType@2000-01-01"
+	body = {}
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		properties = azapi_resource.other.output.properties
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "properties.sku", blockquery.IsOneOf, cty.StringVal("Standard")),
+					Message: "returned value `(unknown)` not in expected values `[Standard]`",
+				},
+			},
+		},
+		{
+			name: "unknown object key ignored",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "identity.userAssignedIdentities.*", blockquery.IsNotNull).WithCompareEachMatch().WithUnknownPolicy(UnknownIgnore),
+			content: `
+resource "azapi_resource" "identity" {
+	type = "otherType@2000-01-01"
+	body = {}
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		identity = {
+			type = "UserAssigned"
+			userAssignedIdentities = {
+				(azapi_resource.identity.id) = {}
+			}
+		}
+	}
+}`,
+			expected: helper.Issues{},
+		},
+		{
+			name: "unknown object key warning",
+			rule: NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "identity.userAssignedIdentities.*", blockquery.IsNotNull).WithUnknownPolicy(UnknownWarn),
+			content: `
+resource "azapi_resource" "identity" {
+	type = "otherType@2000-01-01"
+	body = {}
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		identity = {
+			type = "UserAssigned"
+			userAssignedIdentities = {
+				(azapi_resource.identity.id) = {}
+			}
+		}
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "identity.userAssignedIdentities.*", blockquery.IsNotNull).WithSeverity(tflint.WARNING),
+					Message: "returned value is unknown, so it could not be checked",
+				},
+			},
+		},
 	}
 
 	filename := "main.tf"
@@ -376,6 +1058,274 @@ resource "azapi_resource" "test" {
 	}
 }
 
+func TestAzapiRuleIssueRange(t *testing.T) {
+	content := `
+resource "azapi_resource" "test" {
+  type = "testType@2000-01-01"
+  body = {
+    sku = { name = "Basic" }
+  }
+}`
+	rule := NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "sku.name", blockquery.IsOneOf, blockquery.NewStringResults("Standard")...)
+	runner := helper.TestRunner(t, map[string]string{"main.tf": content})
+	stub := gostub.Stub(&modulecontent.AppFs, mockFs(content))
+	defer stub.Reset()
+	if err := rule.Check(runner); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	helper.AssertIssues(t, helper.Issues{
+		{
+			Rule:    rule,
+			Message: "returned value `Basic` not in expected values `[Standard]`",
+			Range: hcl.Range{
+				Filename: "main.tf",
+				Start:    hcl.Pos{Line: 5, Column: 13},
+				End:      hcl.Pos{Line: 5, Column: 27},
+			},
+		},
+	}, runner.Issues)
+}
+
+func TestAzapiRuleUnknownWarningSeverity(t *testing.T) {
+	content := `
+variable "unknown" {
+  type = string
+}
+
+resource "azapi_resource" "test" {
+	type = "testType@2000-01-01"
+	body = {
+		key = var.unknown
+	}
+}`
+	rule := NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "key", blockquery.IsOneOf, cty.StringVal("bar")).WithUnknownPolicy(UnknownWarn)
+	runner := helper.TestRunner(t, map[string]string{"main.tf": content})
+	stub := gostub.Stub(&modulecontent.AppFs, mockFs(content))
+	defer stub.Reset()
+	require.NoError(t, rule.Check(runner))
+	require.Len(t, runner.Issues, 1)
+	assert.Equal(t, tflint.WARNING, runner.Issues[0].Rule.Severity())
+	assert.Equal(t, "test", runner.Issues[0].Rule.Name())
+	assert.Equal(t, tflint.ERROR, rule.Severity())
+}
+
+func TestAzapiRuleWithChildModules(t *testing.T) {
+	files := map[string]string{
+		"main.tf": `
+module "storage" {
+	source = "./modules/storage"
+	tls    = "TLS1_0"
+}`,
+		"modules/storage/main.tf": `
+variable "tls" {
+	type = string
+}
+
+resource "azapi_resource" "test" {
+	type = "Microsoft.Storage/storageAccounts@2023-01-01"
+	body = {
+		properties = {
+			minimumTlsVersion = var.tls
+		}
+	}
+}`,
+	}
+	fs := afero.NewMemMapFs()
+	for name, c := range files {
+		require.NoError(t, afero.WriteFile(fs, name, []byte(c), os.ModePerm))
+	}
+	newRule := func() *AzApiRule {
+		return NewAzApiRuleQueryMustExist("test", "https://example.com", "Microsoft.Storage/storageAccounts", "", "", "properties.minimumTlsVersion", blockquery.IsOneOf, blockquery.NewStringResults("TLS1_2")...)
+	}
+	stub := gostub.Stub(&modulecontent.AppFs, afero.Afero{Fs: fs})
+	defer stub.Reset()
+
+	runner := helper.TestRunner(t, map[string]string{"main.tf": files["main.tf"]})
+	require.NoError(t, newRule().Check(runner))
+	helper.AssertIssuesWithoutRange(t, helper.Issues{}, runner.Issues)
+
+	runner = helper.TestRunner(t, map[string]string{"main.tf": files["main.tf"]})
+	require.NoError(t, newRule().WithChildModules().Check(runner))
+	helper.AssertIssuesWithoutRange(t, helper.Issues{
+		{
+			Rule:    newRule(),
+			Message: "module.storage.azapi_resource: returned value `TLS1_0` not in expected values `[TLS1_2]` (var.tls from module call at main.tf:4)",
+		},
+	}, runner.Issues)
+}
+
+func TestAzapiRuleWithChildModulesForEach(t *testing.T) {
+	files := map[string]string{
+		"main.tf": `
+module "storage" {
+	source   = "./modules/storage"
+	for_each = toset(["a", "b"])
+	tls      = "TLS1_0"
+}`,
+		"modules/storage/main.tf": `
+variable "tls" {
+	type = string
+}
+
+resource "azapi_resource" "test" {
+	type = "Microsoft.Storage/storageAccounts@2023-01-01"
+	body = {
+		properties = {
+			minimumTlsVersion = var.tls
+		}
+	}
+}`,
+	}
+	fs := afero.NewMemMapFs()
+	for name, c := range files {
+		require.NoError(t, afero.WriteFile(fs, name, []byte(c), os.ModePerm))
+	}
+	newRule := func() *AzApiRule {
+		return NewAzApiRuleQueryMustExist("test", "https://example.com", "Microsoft.Storage/storageAccounts", "", "", "properties.minimumTlsVersion", blockquery.IsOneOf, blockquery.NewStringResults("TLS1_2")...)
+	}
+	stub := gostub.Stub(&modulecontent.AppFs, afero.Afero{Fs: fs})
+	defer stub.Reset()
+
+	runner := helper.TestRunner(t, map[string]string{"main.tf": files["main.tf"]})
+	require.NoError(t, newRule().WithChildModules().Check(runner))
+	helper.AssertIssuesWithoutRange(t, helper.Issues{
+		{
+			Rule:    newRule(),
+			Message: "module.storage[\"a\"].azapi_resource: returned value `TLS1_0` not in expected values `[TLS1_2]` (var.tls from module call at main.tf:5)",
+		},
+		{
+			Rule:    newRule(),
+			Message: "module.storage[\"b\"].azapi_resource: returned value `TLS1_0` not in expected values `[TLS1_2]` (var.tls from module call at main.tf:5)",
+		},
+	}, runner.Issues)
+}
+
+func TestAzapiRuleWithVarFiles(t *testing.T) {
+	files := map[string]string{
+		"main.tf": `
+variable "tls" {
+	type    = string
+	default = "TLS1_2"
+}
+
+resource "azapi_resource" "test" {
+	type = "Microsoft.Storage/storageAccounts@2023-01-01"
+	body = {
+		properties = {
+			minimumTlsVersion = var.tls
+		}
+	}
+}`,
+		"prod.tfvars": `
+tls = "TLS1_0"`,
+	}
+	fs := afero.NewMemMapFs()
+	for name, c := range files {
+		require.NoError(t, afero.WriteFile(fs, name, []byte(c), os.ModePerm))
+	}
+	newRule := func() *AzApiRule {
+		return NewAzApiRuleQueryMustExist("test", "https://example.com", "Microsoft.Storage/storageAccounts", "", "", "properties.minimumTlsVersion", blockquery.IsOneOf, blockquery.NewStringResults("TLS1_2")...)
+	}
+	stub := gostub.Stub(&modulecontent.AppFs, afero.Afero{Fs: fs})
+	defer stub.Reset()
+
+	runner := helper.TestRunner(t, map[string]string{"main.tf": files["main.tf"]})
+	require.NoError(t, newRule().Check(runner))
+	helper.AssertIssuesWithoutRange(t, helper.Issues{}, runner.Issues)
+
+	runner = helper.TestRunner(t, map[string]string{"main.tf": files["main.tf"]})
+	require.NoError(t, newRule().WithFetchOptions(modulecontent.WithVarFiles("prod.tfvars")).Check(runner))
+	helper.AssertIssuesWithoutRange(t, helper.Issues{
+		{
+			Rule:    newRule(),
+			Message: "returned value `TLS1_0` not in expected values `[TLS1_2]` (var.tls from prod.tfvars:2)",
+		},
+	}, runner.Issues)
+
+	runner = helper.TestRunner(t, map[string]string{"main.tf": files["main.tf"]})
+	require.NoError(t, newRule().WithFetchOptions(modulecontent.WithVariables(map[string]cty.Value{"tls": cty.StringVal("TLS1_1")})).Check(runner))
+	helper.AssertIssuesWithoutRange(t, helper.Issues{
+		{
+			Rule:    newRule(),
+			Message: "returned value `TLS1_1` not in expected values `[TLS1_2]` (var.tls from override)",
+		},
+	}, runner.Issues)
+}
+
+func TestAzapiRuleWithVarFilesThroughLocals(t *testing.T) {
+	files := map[string]string{
+		"main.tf": `
+variable "tls" {
+	type    = string
+	default = "TLS1_2"
+}
+
+locals {
+	properties = {
+		minimumTlsVersion = var.tls
+	}
+	body = {
+		properties = local.properties
+	}
+}
+
+resource "azapi_resource" "test" {
+	type = "Microsoft.Storage/storageAccounts@2023-01-01"
+	body = local.body
+}`,
+		"prod.tfvars": `
+tls = "TLS1_0"`,
+	}
+	fs := afero.NewMemMapFs()
+	for name, c := range files {
+		require.NoError(t, afero.WriteFile(fs, name, []byte(c), os.ModePerm))
+	}
+	newRule := func() *AzApiRule {
+		return NewAzApiRuleQueryMustExist("test", "https://example.com", "Microsoft.Storage/storageAccounts", "", "", "properties.minimumTlsVersion", blockquery.IsOneOf, blockquery.NewStringResults("TLS1_2")...)
+	}
+	stub := gostub.Stub(&modulecontent.AppFs, afero.Afero{Fs: fs})
+	defer stub.Reset()
+
+	runner := helper.TestRunner(t, map[string]string{"main.tf": files["main.tf"]})
+	require.NoError(t, newRule().WithFetchOptions(modulecontent.WithVarFiles("prod.tfvars")).Check(runner))
+	helper.AssertIssuesWithoutRange(t, helper.Issues{
+		{
+			Rule:    newRule(),
+			Message: "returned value `TLS1_0` not in expected values `[TLS1_2]` (var.tls from prod.tfvars:2)",
+		},
+	}, runner.Issues)
+}
+
+func TestAzapiRuleInvalidQuery(t *testing.T) {
+	require.Panics(t, func() {
+		NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo.", blockquery.IsNotNull)
+	})
+	require.Panics(t, func() {
+		NewAzApiRuleQueryOptionalExist("test", "https://example.com", "testType", "", "", `foo.#(bar==baz)`, blockquery.IsNotNull)
+	})
+	require.NotPanics(t, func() {
+		NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "2023-05", "", "foo", blockquery.IsNotNull)
+	})
+}
+
+func TestAzapiRuleWithBlockLabels(t *testing.T) {
+	rule := NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsNotNull)
+	require.Equal(t, []string{"azapi_resource"}, rule.LabelOnes())
+	rule.WithBlockLabels("azapi_update_resource", "azapi_resource_action")
+	require.Equal(t, "azapi_update_resource", rule.LabelOne())
+	require.Equal(t, []string{"azapi_update_resource", "azapi_resource_action"}, rule.LabelOnes())
+	require.Panics(t, func() { rule.WithBlockLabels() })
+}
+
+func TestAzapiRuleWithBlockTypes(t *testing.T) {
+	rule := NewAzApiRuleQueryMustExist("test", "https://example.com", "testType", "", "", "foo", blockquery.IsNotNull)
+	require.Equal(t, []string{"resource"}, rule.BlockTypes())
+	rule.WithBlockTypes("data", "ephemeral")
+	require.Equal(t, "data", rule.BlockType())
+	require.Equal(t, []string{"data", "ephemeral"}, rule.BlockTypes())
+	require.Panics(t, func() { rule.WithBlockTypes() })
+}
+
 func mockFs(c string) afero.Afero {
 	fs := afero.NewMemMapFs()
 	_ = afero.WriteFile(fs, "main.tf", []byte(c), os.ModePerm)
@@ -0,0 +1,177 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Property flags used in the bicep-types-az type definitions.
+const (
+	propertyFlagRequired = 1 << 0
+	propertyFlagReadOnly = 1 << 1
+)
+
+// AzureTypes is a set of Azure resource type definitions in the format of the bicep-types-az repository.
+// The definitions are read from a directory with an `index.json` file, which refers to the `types.json` file of each API version.
+// Type files are read when first used and then cached.
+type AzureTypes struct {
+	fsys      fs.FS
+	resources map[string]string // The reference to each resource type definition, keyed by the lower case `type@version`.
+	mu        sync.Mutex
+	files     map[string][]*azureType // The parsed type files, keyed by their path.
+}
+
+// azureTypeIndex is the content of an `index.json` file.
+type azureTypeIndex struct {
+	Resources map[string]typeRef `json:"resources"`
+}
+
+// typeRef is a reference to a type, e.g. `#/3` within the same file or `storage/microsoft.storage/2023-01-01/types.json#/12` from the index.
+type typeRef struct {
+	Ref string `json:"$ref"`
+}
+
+// azureType is a single type definition, the fields that are used depend on the `$type`.
+type azureType struct {
+	Type                 string                   `json:"$type"`
+	Name                 string                   `json:"name"`
+	Body                 *typeRef                 `json:"body"`                 // ResourceType
+	Properties           map[string]azureProperty `json:"properties"`           // ObjectType
+	AdditionalProperties *typeRef                 `json:"additionalProperties"` // ObjectType
+	ItemType             *typeRef                 `json:"itemType"`             // ArrayType
+	Value                json.RawMessage          `json:"value"`                // StringLiteralType
+	Discriminator        string                   `json:"discriminator"`        // DiscriminatedObjectType
+	BaseProperties       map[string]azureProperty `json:"baseProperties"`       // DiscriminatedObjectType
+	Elements             json.RawMessage          `json:"elements"`             // UnionType (list) and DiscriminatedObjectType (map)
+	file                 string                   // The file containing the type, used to resolve its references.
+}
+
+// azureProperty is a property of an object type.
+type azureProperty struct {
+	Type  typeRef `json:"type"`
+	Flags int     `json:"flags"`
+}
+
+// LoadAzureTypes reads the `index.json` file of a bicep-types-az style directory, which may be an embed.FS or os.DirFS.
+func LoadAzureTypes(fsys fs.FS) (*AzureTypes, error) {
+	src, err := fs.ReadFile(fsys, "index.json")
+	if err != nil {
+		return nil, fmt.Errorf("could not read type index: %w", err)
+	}
+	var index azureTypeIndex
+	if err := json.Unmarshal(src, &index); err != nil {
+		return nil, fmt.Errorf("could not parse type index: %w", err)
+	}
+	t := &AzureTypes{
+		fsys:      fsys,
+		resources: make(map[string]string, len(index.Resources)),
+		files:     make(map[string][]*azureType),
+	}
+	for name, ref := range index.Resources {
+		t.resources[strings.ToLower(name)] = ref.Ref
+	}
+	return t, nil
+}
+
+// resourceBody returns the body type of the resource type and API version, or nil if there is no definition.
+func (t *AzureTypes) resourceBody(resourceType, apiVersion string) (*azureType, error) {
+	ref, ok := t.resources[strings.ToLower(resourceType+"@"+apiVersion)]
+	if !ok {
+		return nil, nil
+	}
+	resource, err := t.resolve("", ref)
+	if err != nil {
+		return nil, err
+	}
+	if resource.Type != "ResourceType" || resource.Body == nil {
+		return nil, fmt.Errorf("type definition of %s@%s is not a resource type", resourceType, apiVersion)
+	}
+	return t.resolve(resource.file, resource.Body.Ref)
+}
+
+// resolve returns the type that a reference refers to, relative to the file containing the reference.
+func (t *AzureTypes) resolve(file, ref string) (*azureType, error) {
+	refFile, fragment, ok := strings.Cut(ref, "#/")
+	if !ok {
+		return nil, fmt.Errorf("invalid type reference `%s`", ref)
+	}
+	if refFile != "" {
+		file = path.Clean(refFile)
+	}
+	i, err := strconv.Atoi(fragment)
+	if err != nil {
+		return nil, fmt.Errorf("invalid type reference `%s`", ref)
+	}
+	types, err := t.typeFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= len(types) {
+		return nil, fmt.Errorf("type reference `%s` is out of range in %s", ref, file)
+	}
+	return types[i], nil
+}
+
+// typeFile returns the parsed types of the file, reading it if it is not cached.
+func (t *AzureTypes) typeFile(file string) ([]*azureType, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if types, ok := t.files[file]; ok {
+		return types, nil
+	}
+	src, err := fs.ReadFile(t.fsys, file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read type file: %w", err)
+	}
+	var types []*azureType
+	if err := json.Unmarshal(src, &types); err != nil {
+		return nil, fmt.Errorf("could not parse type file %s: %w", file, err)
+	}
+	for _, typ := range types {
+		typ.file = file
+	}
+	t.files[file] = types
+	return types, nil
+}
+
+// unionElements returns the element types of a UnionType.
+func (t *AzureTypes) unionElements(typ *azureType) ([]*azureType, error) {
+	var refs []typeRef
+	if err := json.Unmarshal(typ.Elements, &refs); err != nil {
+		return nil, fmt.Errorf("invalid union type in %s: %w", typ.file, err)
+	}
+	elems := make([]*azureType, 0, len(refs))
+	for _, ref := range refs {
+		elem, err := t.resolve(typ.file, ref.Ref)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+	}
+	return elems, nil
+}
+
+// discriminatedElements returns the object types of a DiscriminatedObjectType, keyed by the value of the discriminator.
+func (t *AzureTypes) discriminatedElements(typ *azureType) (map[string]*azureType, error) {
+	var refs map[string]typeRef
+	if err := json.Unmarshal(typ.Elements, &refs); err != nil {
+		return nil, fmt.Errorf("invalid discriminated object type %s in %s: %w", typ.Name, typ.file, err)
+	}
+	elems := make(map[string]*azureType, len(refs))
+	for name, ref := range refs {
+		elem, err := t.resolve(typ.file, ref.Ref)
+		if err != nil {
+			return nil, err
+		}
+		elems[name] = elem
+	}
+	return elems, nil
+}
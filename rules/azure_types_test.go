@@ -0,0 +1,245 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// testAzureTypes is a small set of type definitions in the bicep-types-az format.
+var testAzureTypes = fstest.MapFS{
+	"index.json": {Data: []byte(`{
+  "resources": {
+    "Microsoft.Test/things@2024-01-01": {"$ref": "test/2024-01-01/types.json#/0"}
+  }
+}`)},
+	"test/2024-01-01/types.json": {Data: []byte(`[
+  {"$type": "ResourceType", "name": "Microsoft.Test/things@2024-01-01", "body": {"$ref": "#/1"}},
+  {"$type": "ObjectType", "name": "Microsoft.Test/things", "properties": {
+    "id": {"type": {"$ref": "#/2"}, "flags": 10},
+    "name": {"type": {"$ref": "#/2"}, "flags": 9},
+    "location": {"type": {"$ref": "#/2"}, "flags": 1},
+    "properties": {"type": {"$ref": "#/3"}, "flags": 1},
+    "tags": {"type": {"$ref": "#/8"}, "flags": 0}
+  }},
+  {"$type": "StringType"},
+  {"$type": "ObjectType", "name": "ThingProperties", "properties": {
+    "sku": {"type": {"$ref": "#/4"}, "flags": 1},
+    "count": {"type": {"$ref": "#/5"}, "flags": 0},
+    "enabled": {"type": {"$ref": "#/6"}, "flags": 0},
+    "zones": {"type": {"$ref": "#/7"}, "flags": 0},
+    "provisioningState": {"type": {"$ref": "#/2"}, "flags": 2},
+    "source": {"type": {"$ref": "#/9"}, "flags": 0},
+    "tier": {"type": {"$ref": "#/16"}, "flags": 0}
+  }},
+  {"$type": "ObjectType", "name": "Sku", "properties": {
+    "name": {"type": {"$ref": "#/2"}, "flags": 1}
+  }},
+  {"$type": "IntegerType"},
+  {"$type": "BooleanType"},
+  {"$type": "ArrayType", "itemType": {"$ref": "#/2"}},
+  {"$type": "ObjectType", "name": "Tags", "properties": {}, "additionalProperties": {"$ref": "#/2"}},
+  {"$type": "DiscriminatedObjectType", "name": "Source", "discriminator": "kind", "baseProperties": {
+    "description": {"type": {"$ref": "#/2"}, "flags": 0}
+  }, "elements": {"Git": {"$ref": "#/10"}, "Blob": {"$ref": "#/11"}}},
+  {"$type": "ObjectType", "name": "GitSource", "properties": {
+    "kind": {"type": {"$ref": "#/12"}, "flags": 1},
+    "url": {"type": {"$ref": "#/2"}, "flags": 1}
+  }},
+  {"$type": "ObjectType", "name": "BlobSource", "properties": {
+    "kind": {"type": {"$ref": "#/13"}, "flags": 1},
+    "container": {"type": {"$ref": "#/2"}, "flags": 0}
+  }},
+  {"$type": "StringLiteralType", "value": "Git"},
+  {"$type": "StringLiteralType", "value": "Blob"},
+  {"$type": "StringLiteralType", "value": "Basic"},
+  {"$type": "StringLiteralType", "value": "Premium"},
+  {"$type": "UnionType", "elements": [{"$ref": "#/14"}, {"$ref": "#/15"}]}
+]`)},
+}
+
+func TestLoadAzureTypes(t *testing.T) {
+	types, err := LoadAzureTypes(testAzureTypes)
+	require.NoError(t, err)
+
+	body, err := types.resourceBody("microsoft.test/Things", "2024-01-01")
+	require.NoError(t, err)
+	require.NotNil(t, body)
+	require.Equal(t, "ObjectType", body.Type)
+	require.Contains(t, body.Properties, "properties")
+
+	body, err = types.resourceBody("Microsoft.Test/things", "2020-01-01")
+	require.NoError(t, err)
+	require.Nil(t, body)
+
+	_, err = LoadAzureTypes(fstest.MapFS{})
+	require.Error(t, err)
+}
+
+func TestLoadAzureTypesInvalidReference(t *testing.T) {
+	types, err := LoadAzureTypes(fstest.MapFS{
+		"index.json": {Data: []byte(`{"resources": {
+  "Microsoft.Test/missing@2024-01-01": {"$ref": "missing.json#/0"},
+  "Microsoft.Test/outOfRange@2024-01-01": {"$ref": "types.json#/5"},
+  "Microsoft.Test/invalid@2024-01-01": {"$ref": "types.json"}
+}}`)},
+		"types.json": {Data: []byte(`[{"$type": "StringType"}]`)},
+	})
+	require.NoError(t, err)
+	for _, resourceType := range []string{"Microsoft.Test/missing", "Microsoft.Test/outOfRange", "Microsoft.Test/invalid"} {
+		_, err := types.resourceBody(resourceType, "2024-01-01")
+		require.Error(t, err, resourceType)
+	}
+}
+
+func TestAzureTypesValidate(t *testing.T) {
+	types, err := LoadAzureTypes(testAzureTypes)
+	require.NoError(t, err)
+	body, err := types.resourceBody("Microsoft.Test/things", "2024-01-01")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{
+			name:     "valid",
+			body:     `{ properties = { sku = { name = "Standard" }, count = 2, enabled = true, zones = ["1", "2"] }, tags = { env = "test" } }`,
+			expected: nil,
+		},
+		{
+			name:     "top level name and location are not required",
+			body:     `{ properties = { sku = { name = "Standard" } } }`,
+			expected: nil,
+		},
+		{
+			name:     "property names are case-insensitive",
+			body:     `{ Properties = { SKU = { name = "Standard" } } }`,
+			expected: nil,
+		},
+		{
+			name:     "unknown property",
+			body:     `{ properties = { sku = { name = "Standard" }, colour = "blue" } }`,
+			expected: []string{"`properties.colour` is not a known property"},
+		},
+		{
+			name:     "missing required property",
+			body:     `{ properties = { sku = {} } }`,
+			expected: []string{"`properties.sku.name` is required"},
+		},
+		{
+			name:     "null required property",
+			body:     `{ properties = { sku = { name = null } } }`,
+			expected: []string{"`properties.sku.name` is required"},
+		},
+		{
+			name:     "read-only property",
+			body:     `{ id = "abc", properties = { sku = { name = "Standard" }, provisioningState = "Succeeded" } }`,
+			expected: []string{"`id` is read-only", "`properties.provisioningState` is read-only"},
+		},
+		{
+			name: "wrong types",
+			body: `{ properties = { sku = "Standard", count = "2", enabled = 1, zones = "1" } }`,
+			expected: []string{
+				"`properties.count` must be an integer but is string",
+				"`properties.enabled` must be a bool but is number",
+				"`properties.sku` must be an object but is string",
+				"`properties.zones` must be a list but is string",
+			},
+		},
+		{
+			name:     "non-integer number",
+			body:     `{ properties = { sku = { name = "Standard" }, count = 1.5 } }`,
+			expected: []string{"`properties.count` must be an integer but is number"},
+		},
+		{
+			name:     "wrong item type",
+			body:     `{ properties = { sku = { name = "Standard" }, zones = ["1", true] } }`,
+			expected: []string{"`properties.zones[1]` must be a string but is bool"},
+		},
+		{
+			name:     "body is not an object",
+			body:     `"body"`,
+			expected: []string{"body must be an object but is string"},
+		},
+		{
+			name:     "additional properties",
+			body:     `{ properties = { sku = { name = "Standard" } }, tags = { env = 1 } }`,
+			expected: []string{"`tags.env` must be a string but is number"},
+		},
+		{
+			name:     "enum",
+			body:     `{ properties = { sku = { name = "Standard" }, tier = "premium" } }`,
+			expected: nil,
+		},
+		{
+			name:     "enum mismatch",
+			body:     `{ properties = { sku = { name = "Standard" }, tier = "Gold" } }`,
+			expected: []string{"`properties.tier` must be one of `Basic, Premium`"},
+		},
+		{
+			name:     "discriminated object",
+			body:     `{ properties = { sku = { name = "Standard" }, source = { kind = "Git", url = "https://example.com", description = "repo" } } }`,
+			expected: nil,
+		},
+		{
+			name: "discriminated object with properties of another variant",
+			body: `{ properties = { sku = { name = "Standard" }, source = { kind = "Git", container = "c" } } }`,
+			expected: []string{
+				"`properties.source.container` is not a known property",
+				"`properties.source.url` is required",
+			},
+		},
+		{
+			name:     "discriminated object with unknown variant",
+			body:     `{ properties = { sku = { name = "Standard" }, source = { kind = "Svn" } } }`,
+			expected: []string{"`properties.source.kind` must be one of `Blob, Git`"},
+		},
+		{
+			name:     "discriminated object without discriminator",
+			body:     `{ properties = { sku = { name = "Standard" }, source = { url = "https://example.com" } } }`,
+			expected: []string{"`properties.source.kind` is required"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expr, diags := hclsyntax.ParseExpression([]byte(tc.body), "main.tf", hcl.InitialPos)
+			require.False(t, diags.HasErrors(), diags.Error())
+			val, diags := expr.Value(nil)
+			require.False(t, diags.HasErrors(), diags.Error())
+			problems, err := types.validate(val, body, cty.Path{})
+			require.NoError(t, err)
+			var messages []string
+			for _, p := range problems {
+				messages = append(messages, p.message)
+			}
+			require.ElementsMatch(t, tc.expected, messages)
+		})
+	}
+}
+
+func TestAzureTypesValidateUnknown(t *testing.T) {
+	types, err := LoadAzureTypes(testAzureTypes)
+	require.NoError(t, err)
+	body, err := types.resourceBody("Microsoft.Test/things", "2024-01-01")
+	require.NoError(t, err)
+
+	val := cty.ObjectVal(map[string]cty.Value{
+		"properties": cty.ObjectVal(map[string]cty.Value{
+			"sku":    cty.UnknownVal(cty.DynamicPseudoType),
+			"count":  cty.UnknownVal(cty.Number),
+			"source": cty.ObjectVal(map[string]cty.Value{"kind": cty.UnknownVal(cty.String)}),
+		}),
+	})
+	problems, err := types.validate(val, body, cty.Path{})
+	require.NoError(t, err)
+	require.Empty(t, problems)
+}
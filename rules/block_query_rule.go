@@ -0,0 +1,142 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"fmt"
+
+	"github.com/Azure/tflint-helper/blockquery"
+	"github.com/Azure/tflint-helper/modulecontent"
+	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// BlockQueryRule runs the query on an attribute of any block and checks if the result is as expected.
+// The block type, first label and queried attribute are taken from the BlockQuery,
+// e.g. `resource`, `azurerm_storage_account` and `min_tls_version`, or `module`, `""` and `source`.
+// Nested blocks, such as `network_rules`, are not attributes and cannot be queried.
+type BlockQueryRule struct {
+	tflint.DefaultRule // Embed the default rule to reuse its implementation
+	blockquery.BlockQuery
+	attributeQuery
+	link     string
+	ruleName string
+	severity tflint.Severity
+}
+
+var _ tflint.Rule = &BlockQueryRule{}
+var _ severityRule = &BlockQueryRule{}
+var _ modulecontent.BlockFetcher = &BlockQueryRule{}
+
+// NewBlockQueryRuleMustExist creates a rule that runs the query of the BlockQuery against the value of its QueryAttribute.
+// An empty LabelOne matches blocks with any first label, which is needed for blocks such as `module` and `provider`.
+// An issue is raised if the attribute is missing or the query returns no result.
+// The query is compiled when the rule is created, this function panics if the query is malformed.
+func NewBlockQueryRuleMustExist(ruleName, link string, query blockquery.BlockQuery, expectedResults ...cty.Value) *BlockQueryRule {
+	return &BlockQueryRule{
+		BlockQuery: query,
+		attributeQuery: attributeQuery{
+			compiledQuery: blockquery.MustCompile(query.Query),
+			expected:      expectedResults,
+			mustExist:     true,
+		},
+		link:     link,
+		ruleName: ruleName,
+		severity: tflint.ERROR,
+	}
+}
+
+// NewBlockQueryRuleOptionalExist is like NewBlockQueryRuleMustExist, but does not raise an issue if the attribute is missing or the query returns no result.
+func NewBlockQueryRuleOptionalExist(ruleName, link string, query blockquery.BlockQuery, expectedResults ...cty.Value) *BlockQueryRule {
+	r := NewBlockQueryRuleMustExist(ruleName, link, query, expectedResults...)
+	r.mustExist = false
+	return r
+}
+
+// WithCompareEachMatch makes the rule run the compare function against each value found by the query,
+// rather than against the combined result of any wildcards or filters.
+func (r *BlockQueryRule) WithCompareEachMatch() *BlockQueryRule {
+	r.compareEachMatch = true
+	return r
+}
+
+// WithUnknownPolicy sets how values that are not known until apply are treated, the default is UnknownCompare.
+// E.g. use UnknownIgnore to skip values that refer to the outputs of other resources, or UnknownWarn to flag them as warnings.
+func (r *BlockQueryRule) WithUnknownPolicy(policy UnknownPolicy) *BlockQueryRule {
+	r.unknownPolicy = policy
+	return r
+}
+
+// WithSeverity sets the severity of the issues raised by the rule, the default is tflint.ERROR.
+func (r *BlockQueryRule) WithSeverity(severity tflint.Severity) *BlockQueryRule {
+	r.severity = severity
+	return r
+}
+
+// withIssueSeverity returns a copy of the rule that raises issues with the severity.
+func (r *BlockQueryRule) withIssueSeverity(severity tflint.Severity) tflint.Rule {
+	c := *r
+	c.severity = severity
+	return &c
+}
+
+func (r *BlockQueryRule) Link() string {
+	return r.link
+}
+
+func (r *BlockQueryRule) Enabled() bool {
+	return true
+}
+
+func (r *BlockQueryRule) Severity() tflint.Severity {
+	return r.severity
+}
+
+func (r *BlockQueryRule) Name() string {
+	return r.ruleName
+}
+
+func (r *BlockQueryRule) LabelOne() string {
+	return r.BlockQuery.LabelOne
+}
+
+func (r *BlockQueryRule) LabelNames() []string {
+	return r.BlockLabelNames
+}
+
+func (r *BlockQueryRule) BlockType() string {
+	return r.BlockQuery.BlockType
+}
+
+func (r *BlockQueryRule) Attributes() []string {
+	return []string{r.QueryAttribute}
+}
+
+func (r *BlockQueryRule) Check(runner tflint.Runner) error {
+	ctx, blocks, diags := modulecontent.FetchBlocks(r, runner)
+	if diags.HasErrors() {
+		return fmt.Errorf("could not get partial content: %s", diags)
+	}
+	for _, block := range blocks {
+		attr, attrExists := block.Body.Attributes[r.QueryAttribute]
+		if !attrExists {
+			if r.mustExist {
+				runner.EmitIssue( // nolint: errcheck
+					r,
+					fmt.Sprintf("Block does not have a `%s` attribute", r.QueryAttribute),
+					block.DefRange,
+				)
+			}
+			continue
+		}
+		val, diags := ctx.EvaluateExpr(attr.Expr, cty.DynamicPseudoType)
+		if diags.HasErrors() {
+			return fmt.Errorf("could not evaluate %s expression: %s", r.QueryAttribute, diags)
+		}
+		if err := r.check(runner, r, r.CompareFunc, val, attr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/Azure/tflint-helper/blockquery"
+	"github.com/Azure/tflint-helper/modulecontent"
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-linters/tflint-plugin-sdk/helper"
+	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
+)
+
+func TestBlockQueryRule(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rule     tflint.Rule
+		content  string
+		expected helper.Issues
+	}{
+		{
+			name: "azurerm resource nested attribute",
+			rule: NewBlockQueryRuleMustExist("test", "https://example.com",
+				blockquery.NewBlockQuery("resource", "azurerm_storage_account", []string{"type", "name"}, "network_rules", "default_action", blockquery.IsOneOf),
+				blockquery.NewStringResults("Deny")...),
+			content: `
+resource "azurerm_storage_account" "test" {
+	network_rules = {
+		default_action = "Allow"
+	}
+}
+
+resource "azurerm_key_vault" "test" {
+	network_rules = {
+		default_action = "Allow"
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewBlockQueryRuleMustExist("test", "https://example.com", blockquery.NewBlockQuery("resource", "azurerm_storage_account", []string{"type", "name"}, "network_rules", "default_action", blockquery.IsOneOf), blockquery.NewStringResults("Deny")...),
+					Message: "returned value `Allow` not in expected values `[Deny]`",
+				},
+			},
+		},
+		{
+			name: "any module call",
+			rule: NewBlockQueryRuleMustExist("test", "https://example.com",
+				blockquery.NewBlockQuery("module", "", []string{"name"}, "source", "", blockquery.MatchesGlob("Azure/*")),
+			),
+			content: `
+module "good" {
+	source = "Azure/avm-res-storage-storageaccount/azurerm"
+}
+
+module "bad" {
+	source = "git::https://example.com/storage.git"
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewBlockQueryRuleMustExist("test", "https://example.com", blockquery.NewBlockQuery("module", "", []string{"name"}, "source", "", blockquery.MatchesGlob("Azure/*"))),
+					Message: "returned value `git::https://example.com/storage.git` does not match pattern `Azure/*`",
+				},
+			},
+		},
+		{
+			name: "data source",
+			rule: NewBlockQueryRuleMustExist("test", "https://example.com",
+				blockquery.NewBlockQuery("data", "azurerm_client_config", []string{"type", "name"}, "count", "", blockquery.IsNull),
+			),
+			content: `
+data "azurerm_client_config" "current" {}
+`,
+			expected: helper.Issues{
+				{
+					Rule:    NewBlockQueryRuleMustExist("test", "https://example.com", blockquery.NewBlockQuery("data", "azurerm_client_config", []string{"type", "name"}, "count", "", blockquery.IsNull)),
+					Message: "Block does not have a `count` attribute",
+				},
+			},
+		},
+		{
+			name: "provider attribute optional",
+			rule: NewBlockQueryRuleOptionalExist("test", "https://example.com",
+				blockquery.NewBlockQuery("provider", "azurerm", []string{"name"}, "storage_use_azuread", "", blockquery.IsOneOf),
+				blockquery.NewBoolResult(true)),
+			content: `
+provider "azurerm" {
+	features {}
+}`,
+			expected: helper.Issues{},
+		},
+		{
+			name: "provider attribute incorrect",
+			rule: NewBlockQueryRuleOptionalExist("test", "https://example.com",
+				blockquery.NewBlockQuery("provider", "azurerm", []string{"name"}, "storage_use_azuread", "", blockquery.IsOneOf),
+				blockquery.NewBoolResult(true)),
+			content: `
+provider "azurerm" {
+	storage_use_azuread = false
+	features {}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewBlockQueryRuleOptionalExist("test", "https://example.com", blockquery.NewBlockQuery("provider", "azurerm", []string{"name"}, "storage_use_azuread", "", blockquery.IsOneOf), blockquery.NewBoolResult(true)),
+					Message: "returned value `false` not in expected values `[true]`",
+				},
+			},
+		},
+		{
+			name: "query not found in optional rule",
+			rule: NewBlockQueryRuleOptionalExist("test", "https://example.com",
+				blockquery.NewBlockQuery("resource", "azurerm_storage_account", []string{"type", "name"}, "network_rules", "bypass", blockquery.IsNotNull),
+			),
+			content: `
+resource "azurerm_storage_account" "test" {
+	network_rules = {
+		default_action = "Deny"
+	}
+}`,
+			expected: helper.Issues{},
+		},
+	}
+
+	filename := "main.tf"
+	for _, c := range testCases {
+		tc := c
+		t.Run(tc.name, func(t *testing.T) {
+			runner := helper.TestRunner(t, map[string]string{filename: tc.content})
+			stub := gostub.Stub(&modulecontent.AppFs, mockFs(tc.content))
+			defer stub.Reset()
+			if err := tc.rule.Check(runner); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			helper.AssertIssuesWithoutRange(t, tc.expected, runner.Issues)
+		})
+	}
+}
+
+func TestBlockQueryRuleInvalidQuery(t *testing.T) {
+	require.Panics(t, func() {
+		NewBlockQueryRuleMustExist("test", "https://example.com", blockquery.NewBlockQuery("module", "", []string{"name"}, "source", "foo.", blockquery.IsNotNull))
+	})
+}
@@ -0,0 +1,379 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/tflint-helper/blockquery"
+	"github.com/Azure/tflint-helper/modulecontent"
+	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// bodyTopLevelOptional are the properties of a resource body that azapi can set from other attributes,
+// so they are not required in the body.
+var bodyTopLevelOptional = map[string]bool{
+	"name":     true,
+	"location": true,
+	"tags":     true,
+	"identity": true,
+}
+
+// BodySchemaRule validates the `body` attribute of `azapi_resource` resources against the type definition of their resource type and API version.
+// It reports unknown properties, values of the wrong type, read-only properties and missing required properties.
+// Resources whose type and API version have no definition are not checked.
+type BodySchemaRule struct {
+	tflint.DefaultRule // Embed the default rule to reuse its implementation
+	types              *AzureTypes
+	link               string
+	ruleName           string
+	severity           tflint.Severity
+}
+
+var _ tflint.Rule = &BodySchemaRule{}
+var _ modulecontent.BlockFetcher = &BodySchemaRule{}
+
+// NewBodySchemaRule creates a rule that validates azapi resource bodies against the type definitions, see LoadAzureTypes.
+func NewBodySchemaRule(ruleName, link string, types *AzureTypes) *BodySchemaRule {
+	return &BodySchemaRule{
+		types:    types,
+		link:     link,
+		ruleName: ruleName,
+		severity: tflint.ERROR,
+	}
+}
+
+// WithSeverity sets the severity of the issues raised by the rule, the default is tflint.ERROR.
+func (r *BodySchemaRule) WithSeverity(severity tflint.Severity) *BodySchemaRule {
+	r.severity = severity
+	return r
+}
+
+func (r *BodySchemaRule) Link() string {
+	return r.link
+}
+
+func (r *BodySchemaRule) Enabled() bool {
+	return true
+}
+
+func (r *BodySchemaRule) Severity() tflint.Severity {
+	return r.severity
+}
+
+func (r *BodySchemaRule) Name() string {
+	return r.ruleName
+}
+
+func (r *BodySchemaRule) LabelOne() string {
+	return "azapi_resource"
+}
+
+func (r *BodySchemaRule) LabelNames() []string {
+	return []string{"type", "name"}
+}
+
+func (r *BodySchemaRule) BlockType() string {
+	return "resource"
+}
+
+func (r *BodySchemaRule) Attributes() []string {
+	return []string{"type", "body"}
+}
+
+func (r *BodySchemaRule) Check(runner tflint.Runner) error {
+	ctx, resources, diags := modulecontent.FetchBlocks(r, runner)
+	if diags.HasErrors() {
+		return fmt.Errorf("could not get partial content: %s", diags)
+	}
+	for _, resource := range resources {
+		typeAttr, typeAttrExists := resource.Body.Attributes["type"]
+		bodyAttr, bodyAttrExists := resource.Body.Attributes["body"]
+		if !typeAttrExists || !bodyAttrExists {
+			continue
+		}
+		typeVal, diags := ctx.EvaluateExpr(typeAttr.Expr, cty.String)
+		if diags.HasErrors() {
+			return fmt.Errorf("could not evaluate type expression: %s", diags)
+		}
+		if !typeVal.IsKnown() || typeVal.IsNull() {
+			continue
+		}
+		resourceType, apiVersion, found := strings.Cut(typeVal.AsString(), "@")
+		if !found {
+			continue
+		}
+		bodyType, err := r.types.resourceBody(resourceType, apiVersion)
+		if err != nil {
+			return fmt.Errorf("could not load type definition: %w", err)
+		}
+		if bodyType == nil {
+			continue
+		}
+		val, diags := ctx.EvaluateExpr(bodyAttr.Expr, cty.DynamicPseudoType)
+		if diags.HasErrors() {
+			return fmt.Errorf("could not evaluate body expression: %s", diags)
+		}
+		problems, err := r.types.validate(val, bodyType, cty.Path{})
+		if err != nil {
+			return fmt.Errorf("could not validate body: %w", err)
+		}
+		for _, p := range problems {
+			runner.EmitIssue( // nolint: errcheck
+				r,
+				p.message,
+				rangeForPath(bodyAttr, p.path),
+			)
+		}
+	}
+	return nil
+}
+
+// bodyProblem is a difference between a body and its type definition.
+type bodyProblem struct {
+	path    cty.Path
+	message string
+}
+
+// validate checks the value against the type definition and returns the problems found.
+// Unknown and null values are not checked, a null property is treated as missing.
+func (t *AzureTypes) validate(val cty.Value, typ *azureType, path cty.Path) ([]bodyProblem, error) {
+	if !val.IsKnown() || val.IsNull() {
+		return nil, nil
+	}
+	ty := val.Type()
+	switch typ.Type {
+	case "ResourceType":
+		if typ.Body == nil {
+			return nil, nil
+		}
+		body, err := t.resolve(typ.file, typ.Body.Ref)
+		if err != nil {
+			return nil, err
+		}
+		return t.validate(val, body, path)
+	case "ObjectType":
+		if !ty.IsObjectType() && !ty.IsMapType() {
+			return wrongTypeProblem(val, "an object", path), nil
+		}
+		return t.validateObject(val, typ.Properties, typ.AdditionalProperties, typ.file, path)
+	case "DiscriminatedObjectType":
+		if !ty.IsObjectType() && !ty.IsMapType() {
+			return wrongTypeProblem(val, "an object", path), nil
+		}
+		return t.validateDiscriminated(val, typ, path)
+	case "ArrayType":
+		if !ty.IsListType() && !ty.IsTupleType() && !ty.IsSetType() {
+			return wrongTypeProblem(val, "a list", path), nil
+		}
+		if typ.ItemType == nil {
+			return nil, nil
+		}
+		item, err := t.resolve(typ.file, typ.ItemType.Ref)
+		if err != nil {
+			return nil, err
+		}
+		var problems []bodyProblem
+		it := val.ElementIterator()
+		for i := 0; it.Next(); i++ {
+			_, v := it.Element()
+			p, err := t.validate(v, item, path.IndexInt(i))
+			if err != nil {
+				return nil, err
+			}
+			problems = append(problems, p...)
+		}
+		return problems, nil
+	case "StringType":
+		if ty != cty.String {
+			return wrongTypeProblem(val, "a string", path), nil
+		}
+	case "IntegerType":
+		if ty != cty.Number || !val.AsBigFloat().IsInt() {
+			return wrongTypeProblem(val, "an integer", path), nil
+		}
+	case "BooleanType":
+		if ty != cty.Bool {
+			return wrongTypeProblem(val, "a bool", path), nil
+		}
+	case "StringLiteralType":
+		want := stringLiteral(typ)
+		if ty != cty.String || !strings.EqualFold(val.AsString(), want) {
+			return []bodyProblem{{path: path, message: fmt.Sprintf("%s must be `%s`", fmtBodyPath(path), want)}}, nil
+		}
+	case "UnionType":
+		return t.validateUnion(val, typ, path)
+	}
+	return nil, nil
+}
+
+// validateObject checks the attributes of an object against the properties of its type.
+func (t *AzureTypes) validateObject(val cty.Value, props map[string]azureProperty, additional *typeRef, file string, path cty.Path) ([]bodyProblem, error) {
+	var problems []bodyProblem
+	present := make(map[string]bool)
+	it := val.ElementIterator()
+	for it.Next() {
+		k, v := it.Element()
+		name := k.AsString()
+		attrPath := path.GetAttr(name)
+		if v.IsKnown() && v.IsNull() {
+			continue
+		}
+		propName, prop, ok := lookupProperty(props, name)
+		if !ok {
+			if additional == nil {
+				problems = append(problems, bodyProblem{path: attrPath, message: fmt.Sprintf("%s is not a known property", fmtBodyPath(attrPath))})
+				continue
+			}
+			addType, err := t.resolve(file, additional.Ref)
+			if err != nil {
+				return nil, err
+			}
+			p, err := t.validate(v, addType, attrPath)
+			if err != nil {
+				return nil, err
+			}
+			problems = append(problems, p...)
+			continue
+		}
+		present[propName] = true
+		if prop.Flags&propertyFlagReadOnly != 0 {
+			problems = append(problems, bodyProblem{path: attrPath, message: fmt.Sprintf("%s is read-only", fmtBodyPath(attrPath))})
+			continue
+		}
+		propType, err := t.resolve(file, prop.Type.Ref)
+		if err != nil {
+			return nil, err
+		}
+		p, err := t.validate(v, propType, attrPath)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, p...)
+	}
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop := props[name]
+		if prop.Flags&propertyFlagRequired == 0 || prop.Flags&propertyFlagReadOnly != 0 || present[name] {
+			continue
+		}
+		if len(path) == 0 && bodyTopLevelOptional[name] {
+			continue
+		}
+		attrPath := path.GetAttr(name)
+		problems = append(problems, bodyProblem{path: attrPath, message: fmt.Sprintf("%s is required", fmtBodyPath(attrPath))})
+	}
+	return problems, nil
+}
+
+// validateDiscriminated checks an object against the variant of a DiscriminatedObjectType selected by its discriminator.
+func (t *AzureTypes) validateDiscriminated(val cty.Value, typ *azureType, path cty.Path) ([]bodyProblem, error) {
+	discPath := path.GetAttr(typ.Discriminator)
+	var disc cty.Value
+	if val.Type().IsObjectType() && val.Type().HasAttribute(typ.Discriminator) {
+		disc = val.GetAttr(typ.Discriminator)
+	} else if val.Type().IsMapType() {
+		disc = val.Index(cty.StringVal(typ.Discriminator))
+	}
+	if disc == cty.NilVal || (disc.IsKnown() && disc.IsNull()) {
+		return []bodyProblem{{path: discPath, message: fmt.Sprintf("%s is required", fmtBodyPath(discPath))}}, nil
+	}
+	if !disc.IsKnown() {
+		return nil, nil
+	}
+	elems, err := t.discriminatedElements(typ)
+	if err != nil {
+		return nil, err
+	}
+	var elem *azureType
+	names := make([]string, 0, len(elems))
+	for name, e := range elems {
+		names = append(names, name)
+		if disc.Type() == cty.String && strings.EqualFold(disc.AsString(), name) {
+			elem = e
+		}
+	}
+	if elem == nil {
+		sort.Strings(names)
+		return []bodyProblem{{path: discPath, message: fmt.Sprintf("%s must be one of `%s`", fmtBodyPath(discPath), strings.Join(names, ", "))}}, nil
+	}
+	props := make(map[string]azureProperty, len(typ.BaseProperties)+len(elem.Properties))
+	for name, p := range typ.BaseProperties {
+		props[name] = p
+	}
+	for name, p := range elem.Properties {
+		props[name] = p
+	}
+	return t.validateObject(val, props, elem.AdditionalProperties, elem.file, path)
+}
+
+// validateUnion checks that the value matches at least one of the element types of a UnionType.
+func (t *AzureTypes) validateUnion(val cty.Value, typ *azureType, path cty.Path) ([]bodyProblem, error) {
+	elems, err := t.unionElements(typ)
+	if err != nil {
+		return nil, err
+	}
+	var literals []string
+	var firstProblems []bodyProblem
+	for _, elem := range elems {
+		p, err := t.validate(val, elem, path)
+		if err != nil {
+			return nil, err
+		}
+		if len(p) == 0 {
+			return nil, nil
+		}
+		if firstProblems == nil {
+			firstProblems = p
+		}
+		if elem.Type == "StringLiteralType" {
+			literals = append(literals, stringLiteral(elem))
+		}
+	}
+	if len(literals) == len(elems) && len(literals) > 0 {
+		return []bodyProblem{{path: path, message: fmt.Sprintf("%s must be one of `%s`", fmtBodyPath(path), strings.Join(literals, ", "))}}, nil
+	}
+	return firstProblems, nil
+}
+
+// lookupProperty finds a property by name, falling back to a case-insensitive match as Azure property names are case-insensitive.
+func lookupProperty(props map[string]azureProperty, name string) (string, azureProperty, bool) {
+	if p, ok := props[name]; ok {
+		return name, p, true
+	}
+	for propName, p := range props {
+		if strings.EqualFold(propName, name) {
+			return propName, p, true
+		}
+	}
+	return "", azureProperty{}, false
+}
+
+// stringLiteral returns the value of a StringLiteralType.
+func stringLiteral(typ *azureType) string {
+	var s string
+	_ = json.Unmarshal(typ.Value, &s)
+	return s
+}
+
+// wrongTypeProblem reports that the value is not of the expected type.
+func wrongTypeProblem(val cty.Value, want string, path cty.Path) []bodyProblem {
+	return []bodyProblem{{path: path, message: fmt.Sprintf("%s must be %s but is %s", fmtBodyPath(path), want, val.Type().FriendlyName())}}
+}
+
+// fmtBodyPath formats the path of a body property for a message.
+func fmtBodyPath(path cty.Path) string {
+	if len(path) == 0 {
+		return "body"
+	}
+	return fmt.Sprintf("`%s`", blockquery.FormatPath(path))
+}
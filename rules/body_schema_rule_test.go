@@ -0,0 +1,117 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/Azure/tflint-helper/modulecontent"
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-linters/tflint-plugin-sdk/helper"
+	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
+)
+
+func TestBodySchemaRule(t *testing.T) {
+	types, err := LoadAzureTypes(testAzureTypes)
+	require.NoError(t, err)
+	testCases := []struct {
+		name     string
+		rule     tflint.Rule
+		content  string
+		expected helper.Issues
+	}{
+		{
+			name: "valid body",
+			rule: NewBodySchemaRule("test", "https://example.com", types),
+			content: `
+resource "azapi_resource" "test" {
+	type = "Microsoft.Test/things@2024-01-01"
+	body = {
+		properties = {
+			sku = {
+				name = "Standard"
+			}
+		}
+	}
+}`,
+			expected: helper.Issues{},
+		},
+		{
+			name: "invalid body",
+			rule: NewBodySchemaRule("test", "https://example.com", types),
+			content: `
+variable "zones" {
+	type    = list(string)
+	default = ["1"]
+}
+
+resource "azapi_resource" "test" {
+	type = "Microsoft.Test/things@2024-01-01"
+	body = {
+		properties = {
+			sku               = {}
+			provisioningState = "Succeeded"
+			zones             = var.zones
+			colour            = "blue"
+		}
+	}
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewBodySchemaRule("test", "https://example.com", types),
+					Message: "`properties.colour` is not a known property",
+				},
+				{
+					Rule:    NewBodySchemaRule("test", "https://example.com", types),
+					Message: "`properties.provisioningState` is read-only",
+				},
+				{
+					Rule:    NewBodySchemaRule("test", "https://example.com", types),
+					Message: "`properties.sku.name` is required",
+				},
+			},
+		},
+		{
+			name: "no type definition",
+			rule: NewBodySchemaRule("test", "https://example.com", types),
+			content: `
+resource "azapi_resource" "test" {
+	type = "Microsoft.Test/things@2020-01-01"
+	body = {
+		colour = "blue"
+	}
+}`,
+			expected: helper.Issues{},
+		},
+		{
+			name: "unknown body",
+			rule: NewBodySchemaRule("test", "https://example.com", types),
+			content: `
+variable "body" {
+	type = any
+}
+
+resource "azapi_resource" "test" {
+	type = "Microsoft.Test/things@2024-01-01"
+	body = var.body
+}`,
+			expected: helper.Issues{},
+		},
+	}
+
+	filename := "main.tf"
+	for _, c := range testCases {
+		tc := c
+		t.Run(tc.name, func(t *testing.T) {
+			runner := helper.TestRunner(t, map[string]string{filename: tc.content})
+			stub := gostub.Stub(&modulecontent.AppFs, mockFs(tc.content))
+			defer stub.Reset()
+			if err := tc.rule.Check(runner); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			helper.AssertIssuesWithoutRange(t, tc.expected, runner.Issues)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/Azure/tflint-helper/blockquery"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// compareFuncFactory builds a compare function and the values to pass to it from the expected values of a rule definition.
+type compareFuncFactory func(expected []cty.Value) (blockquery.ResultCompareFunc, []cty.Value, error)
+
+// compareFuncs are the compare functions that can be named in a rule definition file, keyed by their name in the blockquery package.
+var compareFuncs = map[string]compareFuncFactory{
+	"IsKnown":                withExpected(blockquery.IsKnown),
+	"IsNotKnown":             withExpected(blockquery.IsNotKnown),
+	"IsNull":                 withExpected(blockquery.IsNull),
+	"IsNotNull":              withExpected(blockquery.IsNotNull),
+	"IsOneOf":                withExpected(blockquery.IsOneOf),
+	"EachIsOneOf":            withExpected(blockquery.EachIsOneOf),
+	"IsOneOfIgnoreCase":      withExpected(blockquery.IsOneOfIgnoreCase),
+	"EachIsOneOfIgnoreCase":  withExpected(blockquery.EachIsOneOfIgnoreCase),
+	"MatchesRegex":           withPatterns(blockquery.MatchesRegex, validateRegex),
+	"EachMatchesRegex":       withPatterns(blockquery.EachMatchesRegex, validateRegex),
+	"MatchesGlob":            withPatterns(blockquery.MatchesGlob, nil),
+	"EachMatchesGlob":        withPatterns(blockquery.EachMatchesGlob, nil),
+	"GreaterThan":            withNumbers(blockquery.GreaterThan, 1),
+	"GreaterThanOrEqual":     withNumbers(blockquery.GreaterThanOrEqual, 1),
+	"LessThan":               withNumbers(blockquery.LessThan, 1),
+	"LessThanOrEqual":        withNumbers(blockquery.LessThanOrEqual, 1),
+	"InRange":                withNumbers(blockquery.InRange, 2),
+	"InRangeExclusive":       withNumbers(blockquery.InRangeExclusive, 2),
+	"EachGreaterThan":        withNumbers(blockquery.EachGreaterThan, 1),
+	"EachGreaterThanOrEqual": withNumbers(blockquery.EachGreaterThanOrEqual, 1),
+	"EachLessThan":           withNumbers(blockquery.EachLessThan, 1),
+	"EachLessThanOrEqual":    withNumbers(blockquery.EachLessThanOrEqual, 1),
+	"EachInRange":            withNumbers(blockquery.EachInRange, 2),
+	"EachInRangeExclusive":   withNumbers(blockquery.EachInRangeExclusive, 2),
+	"Contains":               withExpected(blockquery.Contains),
+	"ContainsAll":            withExpected(blockquery.ContainsAll),
+	"IsSubsetOf":             withExpected(blockquery.IsSubsetOf),
+	"IsSupersetOf":           withExpected(blockquery.IsSupersetOf),
+	"LengthEquals":           withNumbers(blockquery.LengthEquals, 1),
+	"LengthAtLeast":          withNumbers(blockquery.LengthAtLeast, 1),
+	"IsEmpty":                withExpected(blockquery.IsEmpty),
+	"MatchesShape":           withExpected(blockquery.MatchesShape),
+}
+
+// compareFuncNames returns the names of the compare functions that can be used in a rule definition file, in lexical order.
+func compareFuncNames() []string {
+	names := make([]string, 0, len(compareFuncs))
+	for name := range compareFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withExpected is a factory for compare functions that take the expected values as they are.
+func withExpected(cmpFn blockquery.ResultCompareFunc) compareFuncFactory {
+	return func(expected []cty.Value) (blockquery.ResultCompareFunc, []cty.Value, error) {
+		return cmpFn, expected, nil
+	}
+}
+
+// withNumbers is a factory for compare functions that take a fixed number of numeric bounds.
+func withNumbers(cmpFn blockquery.ResultCompareFunc, count int) compareFuncFactory {
+	return func(expected []cty.Value) (blockquery.ResultCompareFunc, []cty.Value, error) {
+		if len(expected) != count {
+			return nil, nil, fmt.Errorf("expected %d numeric values but got %d", count, len(expected))
+		}
+		nums := make([]cty.Value, len(expected))
+		for i, v := range expected {
+			n, err := convert.Convert(v, cty.Number)
+			if err != nil || n.IsNull() || !n.IsKnown() {
+				return nil, nil, fmt.Errorf("expected value %d is not a number", i+1)
+			}
+			nums[i] = n
+		}
+		return cmpFn, nums, nil
+	}
+}
+
+// withPatterns is a factory for compare functions that are built from string patterns, such as blockquery.MatchesRegex.
+// The patterns are validated so that a malformed pattern is reported rather than causing a panic.
+func withPatterns(build func(...string) blockquery.ResultCompareFunc, validate func(string) error) compareFuncFactory {
+	return func(expected []cty.Value) (blockquery.ResultCompareFunc, []cty.Value, error) {
+		if len(expected) == 0 {
+			return nil, nil, fmt.Errorf("at least one pattern is required")
+		}
+		patterns := make([]string, len(expected))
+		for i, v := range expected {
+			s, err := convert.Convert(v, cty.String)
+			if err != nil || s.IsNull() || !s.IsKnown() {
+				return nil, nil, fmt.Errorf("pattern %d is not a string", i+1)
+			}
+			patterns[i] = s.AsString()
+			if validate == nil {
+				continue
+			}
+			if err := validate(patterns[i]); err != nil {
+				return nil, nil, err
+			}
+		}
+		return build(patterns...), nil, nil
+	}
+}
+
+// validateRegex checks that the pattern is a valid regular expression.
+func validateRegex(pattern string) error {
+	_, err := regexp.Compile(pattern)
+	return err
+}
@@ -0,0 +1,488 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/tflint-helper/blockquery"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/spf13/afero"
+	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"gopkg.in/yaml.v3"
+)
+
+// ruleDefinitionSchema lists the arguments of a rule definition.
+var ruleDefinitionSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "link"},
+		{Name: "severity"},
+		{Name: "resource_type", Required: true},
+		{Name: "minimum_api_version"},
+		{Name: "maximum_api_version"},
+		{Name: "preview_versions"},
+		{Name: "query", Required: true},
+		{Name: "compare", Required: true},
+		{Name: "expected"},
+		{Name: "must_exist"},
+		{Name: "compare_each_match"},
+		{Name: "unknown_values"},
+	},
+}
+
+// ruleFileSchema is the top level schema of an HCL rule definition file.
+var ruleFileSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "rule", LabelNames: []string{"name"}},
+	},
+}
+
+// ruleDefinition is a rule read from a definition file, before it is validated.
+type ruleDefinition struct {
+	name      string
+	nameRange hcl.Range
+	defRange  hcl.Range
+	attrs     map[string]definitionAttr
+}
+
+// definitionAttr is the value of a rule definition argument and its position in the file.
+type definitionAttr struct {
+	val cty.Value
+	rng hcl.Range
+}
+
+// LoadFromFile reads AzApiRule definitions from a file on disk, see LoadFromFS.
+func LoadFromFile(path string) ([]tflint.Rule, hcl.Diagnostics) {
+	return LoadFromFS(afero.NewOsFs(), path)
+}
+
+// LoadFromFS reads AzApiRule definitions from a file in the filesystem.
+// Files with a `.yaml` or `.yml` extension are read as YAML, `.json` files as HCL JSON and all others as HCL.
+// Each rule is validated, including its query and compare function, and problems are returned as diagnostics with their position in the file.
+func LoadFromFS(fs afero.Fs, path string) ([]tflint.Rule, hcl.Diagnostics) {
+	src, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to read rule definitions",
+			Detail:   fmt.Sprintf("Could not read %s: %s.", path, err),
+		}}
+	}
+	var defs []ruleDefinition
+	var diags hcl.Diagnostics
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		defs, diags = parseYAMLDefinitions(src, path)
+	case ".json":
+		file, parseDiags := hclparse.NewParser().ParseJSON(src, path)
+		if parseDiags.HasErrors() {
+			return nil, parseDiags
+		}
+		defs, diags = parseHCLDefinitions(file.Body)
+	default:
+		file, parseDiags := hclparse.NewParser().ParseHCL(src, path)
+		if parseDiags.HasErrors() {
+			return nil, parseDiags
+		}
+		defs, diags = parseHCLDefinitions(file.Body)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	rules := make([]tflint.Rule, 0, len(defs))
+	seen := make(map[string]hcl.Range, len(defs))
+	for _, def := range defs {
+		if prev, ok := seen[def.name]; ok {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate rule",
+				Detail:   fmt.Sprintf("A rule named %q was already defined at %s.", def.name, prev),
+				Subject:  def.nameRange.Ptr(),
+			})
+			continue
+		}
+		seen[def.name] = def.nameRange
+		rule, ruleDiags := buildRule(def)
+		diags = diags.Extend(ruleDiags)
+		if !ruleDiags.HasErrors() {
+			rules = append(rules, rule)
+		}
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return rules, diags
+}
+
+// parseHCLDefinitions reads the `rule` blocks of an HCL or HCL JSON file.
+func parseHCLDefinitions(body hcl.Body) ([]ruleDefinition, hcl.Diagnostics) {
+	content, diags := body.Content(ruleFileSchema)
+	defs := make([]ruleDefinition, 0, len(content.Blocks))
+	for _, block := range content.Blocks {
+		ruleContent, ruleDiags := block.Body.Content(ruleDefinitionSchema)
+		diags = diags.Extend(ruleDiags)
+		def := ruleDefinition{
+			name:      block.Labels[0],
+			nameRange: block.LabelRanges[0],
+			defRange:  block.DefRange,
+			attrs:     make(map[string]definitionAttr, len(ruleContent.Attributes)),
+		}
+		for name, attr := range ruleContent.Attributes {
+			val, valDiags := attr.Expr.Value(nil)
+			diags = diags.Extend(valDiags)
+			def.attrs[name] = definitionAttr{val: val, rng: attr.Expr.Range()}
+		}
+		defs = append(defs, def)
+	}
+	return defs, diags
+}
+
+// parseYAMLDefinitions reads the list of rules under the top level `rules` key of a YAML file.
+// Each rule is a mapping with a `name` key and the same arguments as an HCL rule block.
+func parseYAMLDefinitions(src []byte, filename string) ([]ruleDefinition, hcl.Diagnostics) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(src, &doc); err != nil {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid YAML",
+			Detail:   fmt.Sprintf("Could not parse %s: %s.", filename, err),
+			Subject:  &hcl.Range{Filename: filename, Start: hcl.InitialPos, End: hcl.InitialPos},
+		}}
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, hcl.Diagnostics{yamlDiagnostic(filename, root, "Invalid rule definitions", "The document must be a mapping with a `rules` key.")}
+	}
+	var diags hcl.Diagnostics
+	var rulesNode *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key := root.Content[i]
+		if key.Value != "rules" {
+			diags = diags.Append(yamlDiagnostic(filename, key, "Unsupported argument", fmt.Sprintf("An argument named %q is not expected here.", key.Value)))
+			continue
+		}
+		rulesNode = root.Content[i+1]
+	}
+	if rulesNode == nil {
+		return nil, diags
+	}
+	if rulesNode.Kind != yaml.SequenceNode {
+		return nil, diags.Append(yamlDiagnostic(filename, rulesNode, "Invalid rule definitions", "The `rules` key must contain a list of rules."))
+	}
+	defs := make([]ruleDefinition, 0, len(rulesNode.Content))
+	for _, ruleNode := range rulesNode.Content {
+		def, ruleDiags := parseYAMLDefinition(ruleNode, filename)
+		diags = diags.Extend(ruleDiags)
+		if !ruleDiags.HasErrors() {
+			defs = append(defs, def)
+		}
+	}
+	return defs, diags
+}
+
+// parseYAMLDefinition reads a single rule mapping, checking its arguments against ruleDefinitionSchema.
+func parseYAMLDefinition(node *yaml.Node, filename string) (ruleDefinition, hcl.Diagnostics) {
+	def := ruleDefinition{defRange: yamlRange(filename, node), attrs: make(map[string]definitionAttr)}
+	if node.Kind != yaml.MappingNode {
+		return def, hcl.Diagnostics{yamlDiagnostic(filename, node, "Invalid rule definition", "A rule must be a mapping of arguments.")}
+	}
+	allowed := make(map[string]bool, len(ruleDefinitionSchema.Attributes))
+	for _, a := range ruleDefinitionSchema.Attributes {
+		allowed[a.Name] = true
+	}
+	var diags hcl.Diagnostics
+	nameFound := false
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, valNode := node.Content[i], node.Content[i+1]
+		if key.Value == "name" {
+			if valNode.Kind != yaml.ScalarNode || valNode.Value == "" {
+				diags = diags.Append(yamlDiagnostic(filename, valNode, "Invalid rule name", "The rule name must be a non-empty string."))
+				continue
+			}
+			def.name = valNode.Value
+			def.nameRange = yamlRange(filename, valNode)
+			nameFound = true
+			continue
+		}
+		if !allowed[key.Value] {
+			diags = diags.Append(yamlDiagnostic(filename, key, "Unsupported argument", fmt.Sprintf("An argument named %q is not expected here.", key.Value)))
+			continue
+		}
+		val, err := yamlNodeToCty(valNode)
+		if err != nil {
+			diags = diags.Append(yamlDiagnostic(filename, valNode, "Invalid value", fmt.Sprintf("Could not read %s: %s.", key.Value, err)))
+			continue
+		}
+		def.attrs[key.Value] = definitionAttr{val: val, rng: yamlRange(filename, valNode)}
+	}
+	if !nameFound {
+		diags = diags.Append(yamlDiagnostic(filename, node, "Missing required argument", `The argument "name" is required, but no definition was found.`))
+	}
+	for _, a := range ruleDefinitionSchema.Attributes {
+		if _, ok := def.attrs[a.Name]; a.Required && !ok {
+			diags = diags.Append(yamlDiagnostic(filename, node, "Missing required argument", fmt.Sprintf("The argument %q is required, but no definition was found.", a.Name)))
+		}
+	}
+	return def, diags
+}
+
+// yamlNodeToCty converts a YAML node to a cty value, in the same way as the equivalent HCL literal would be.
+func yamlNodeToCty(node *yaml.Node) (cty.Value, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return yamlNodeToCty(node.Alias)
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			return cty.EmptyTupleVal, nil
+		}
+		vals := make([]cty.Value, len(node.Content))
+		for i, n := range node.Content {
+			v, err := yamlNodeToCty(n)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			vals[i] = v
+		}
+		return cty.TupleVal(vals), nil
+	case yaml.MappingNode:
+		attrs := make(map[string]cty.Value, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v, err := yamlNodeToCty(node.Content[i+1])
+			if err != nil {
+				return cty.NilVal, err
+			}
+			attrs[node.Content[i].Value] = v
+		}
+		return cty.ObjectVal(attrs), nil
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			return cty.NullVal(cty.DynamicPseudoType), nil
+		case "!!bool":
+			var b bool
+			if err := node.Decode(&b); err != nil {
+				return cty.NilVal, err
+			}
+			return cty.BoolVal(b), nil
+		case "!!int", "!!float":
+			return cty.ParseNumberVal(node.Value)
+		}
+		return cty.StringVal(node.Value), nil
+	}
+	return cty.NilVal, fmt.Errorf("unsupported YAML node")
+}
+
+// yamlRange returns the position of a YAML node as an HCL range.
+func yamlRange(filename string, node *yaml.Node) hcl.Range {
+	pos := hcl.Pos{Line: node.Line, Column: node.Column}
+	return hcl.Range{Filename: filename, Start: pos, End: pos}
+}
+
+// yamlDiagnostic creates an error diagnostic at the position of a YAML node.
+func yamlDiagnostic(filename string, node *yaml.Node, summary, detail string) *hcl.Diagnostic {
+	rng := yamlRange(filename, node)
+	return &hcl.Diagnostic{Severity: hcl.DiagError, Summary: summary, Detail: detail, Subject: &rng}
+}
+
+// buildRule validates a rule definition and creates the rule.
+func buildRule(def ruleDefinition) (tflint.Rule, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	if def.name == "" {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid rule name",
+			Detail:   "The rule name must be a non-empty string.",
+			Subject:  def.nameRange.Ptr(),
+		})
+	}
+	link, linkDiags := def.stringAttr("link")
+	diags = diags.Extend(linkDiags)
+	resourceType, typeDiags := def.stringAttr("resource_type")
+	diags = diags.Extend(typeDiags)
+	minimumApiVersion, minDiags := def.stringAttr("minimum_api_version")
+	diags = diags.Extend(minDiags)
+	maximumApiVersion, maxDiags := def.stringAttr("maximum_api_version")
+	diags = diags.Extend(maxDiags)
+	previewPolicy, previewDiags := def.previewPolicy()
+	diags = diags.Extend(previewDiags)
+	query, queryDiags := def.stringAttr("query")
+	diags = diags.Extend(queryDiags)
+	if !queryDiags.HasErrors() {
+		if _, err := blockquery.Compile(query); err != nil {
+			diags = diags.Append(def.attrDiagnostic("query", "Invalid query", err.Error()+"."))
+		}
+	}
+	severity, severityDiags := def.severity()
+	diags = diags.Extend(severityDiags)
+	mustExist, mustExistDiags := def.boolAttr("must_exist", true)
+	diags = diags.Extend(mustExistDiags)
+	compareEachMatch, eachDiags := def.boolAttr("compare_each_match", false)
+	diags = diags.Extend(eachDiags)
+	unknownPolicy, unknownDiags := def.unknownPolicy()
+	diags = diags.Extend(unknownDiags)
+	cmpFn, expected, cmpDiags := def.compareFunc()
+	diags = diags.Extend(cmpDiags)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var rule *AzApiRule
+	if mustExist {
+		rule = NewAzApiRuleQueryMustExist(def.name, link, resourceType, minimumApiVersion, maximumApiVersion, query, cmpFn, expected...)
+	} else {
+		rule = NewAzApiRuleQueryOptionalExist(def.name, link, resourceType, minimumApiVersion, maximumApiVersion, query, cmpFn, expected...)
+	}
+	rule.WithSeverity(severity)
+	rule.WithPreviewPolicy(previewPolicy)
+	rule.WithUnknownPolicy(unknownPolicy)
+	if compareEachMatch {
+		rule.WithCompareEachMatch()
+	}
+	return rule, diags
+}
+
+// stringAttr returns the value of a string argument, or an empty string if it is not set.
+func (d ruleDefinition) stringAttr(name string) (string, hcl.Diagnostics) {
+	attr, ok := d.attrs[name]
+	if !ok || attr.val.IsNull() {
+		return "", nil
+	}
+	val, err := convert.Convert(attr.val, cty.String)
+	if err != nil {
+		return "", hcl.Diagnostics{d.attrDiagnostic(name, "Invalid value", fmt.Sprintf("%s must be a string.", name))}
+	}
+	return val.AsString(), nil
+}
+
+// previewPolicy returns how the rule treats pre-release API versions, which defaults to allow.
+func (d ruleDefinition) previewPolicy() (PreviewPolicy, hcl.Diagnostics) {
+	s, diags := d.stringAttr("preview_versions")
+	if diags.HasErrors() {
+		return PreviewAllow, diags
+	}
+	switch strings.ToLower(s) {
+	case "", "allow":
+		return PreviewAllow, nil
+	case "skip":
+		return PreviewSkip, nil
+	case "deny":
+		return PreviewDeny, nil
+	}
+	return PreviewAllow, hcl.Diagnostics{d.attrDiagnostic("preview_versions", "Invalid preview policy", fmt.Sprintf("Preview policy %q must be one of allow, skip or deny.", s))}
+}
+
+// unknownPolicy returns how the rule treats values that are not known until apply, which defaults to compare.
+func (d ruleDefinition) unknownPolicy() (UnknownPolicy, hcl.Diagnostics) {
+	s, diags := d.stringAttr("unknown_values")
+	if diags.HasErrors() {
+		return UnknownCompare, diags
+	}
+	switch strings.ToLower(s) {
+	case "", "compare":
+		return UnknownCompare, nil
+	case "ignore":
+		return UnknownIgnore, nil
+	case "warn":
+		return UnknownWarn, nil
+	case "fail":
+		return UnknownFail, nil
+	}
+	return UnknownCompare, hcl.Diagnostics{d.attrDiagnostic("unknown_values", "Invalid unknown value policy", fmt.Sprintf("Unknown value policy %q must be one of compare, ignore, warn or fail.", s))}
+}
+
+// boolAttr returns the value of a bool argument, or the default if it is not set.
+func (d ruleDefinition) boolAttr(name string, def bool) (bool, hcl.Diagnostics) {
+	attr, ok := d.attrs[name]
+	if !ok || attr.val.IsNull() {
+		return def, nil
+	}
+	val, err := convert.Convert(attr.val, cty.Bool)
+	if err != nil {
+		return def, hcl.Diagnostics{d.attrDiagnostic(name, "Invalid value", fmt.Sprintf("%s must be a bool.", name))}
+	}
+	return val.True(), nil
+}
+
+// severity returns the severity of the rule, which defaults to error.
+func (d ruleDefinition) severity() (tflint.Severity, hcl.Diagnostics) {
+	s, diags := d.stringAttr("severity")
+	if diags.HasErrors() {
+		return tflint.ERROR, diags
+	}
+	switch strings.ToLower(s) {
+	case "", "error":
+		return tflint.ERROR, nil
+	case "warning":
+		return tflint.WARNING, nil
+	case "notice":
+		return tflint.NOTICE, nil
+	}
+	return tflint.ERROR, hcl.Diagnostics{d.attrDiagnostic("severity", "Invalid severity", fmt.Sprintf("Severity %q must be one of error, warning or notice.", s))}
+}
+
+// compareFunc looks up the named compare function and builds it with the expected values.
+func (d ruleDefinition) compareFunc() (blockquery.ResultCompareFunc, []cty.Value, hcl.Diagnostics) {
+	name, diags := d.stringAttr("compare")
+	if diags.HasErrors() {
+		return nil, nil, diags
+	}
+	factory, ok := compareFuncs[name]
+	if !ok {
+		return nil, nil, hcl.Diagnostics{d.attrDiagnostic("compare", "Unknown compare function", fmt.Sprintf("%q is not a compare function, use one of %s.", name, strings.Join(compareFuncNames(), ", ")))}
+	}
+	expected, err := d.expectedValues()
+	if err == nil {
+		var cmpFn blockquery.ResultCompareFunc
+		cmpFn, expected, err = factory(expected)
+		if err == nil {
+			return cmpFn, expected, nil
+		}
+	}
+	rngName := "expected"
+	if _, ok := d.attrs[rngName]; !ok {
+		rngName = "compare"
+	}
+	return nil, nil, hcl.Diagnostics{d.attrDiagnostic(rngName, "Invalid expected values", fmt.Sprintf("Invalid expected values for %s: %s.", name, err))}
+}
+
+// expectedValues returns the expected values of the rule.
+// A list is a list of expected values, any other value is a single expected value.
+func (d ruleDefinition) expectedValues() ([]cty.Value, error) {
+	attr, ok := d.attrs["expected"]
+	if !ok || attr.val.IsNull() {
+		return nil, nil
+	}
+	if !attr.val.IsWhollyKnown() {
+		return nil, errors.New("values must be known")
+	}
+	ty := attr.val.Type()
+	if !ty.IsListType() && !ty.IsTupleType() && !ty.IsSetType() {
+		return []cty.Value{attr.val}, nil
+	}
+	vals := make([]cty.Value, 0, attr.val.LengthInt())
+	it := attr.val.ElementIterator()
+	for it.Next() {
+		_, v := it.Element()
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+// attrDiagnostic creates an error diagnostic at the position of the named argument, or of the rule if it is not set.
+func (d ruleDefinition) attrDiagnostic(name, summary, detail string) *hcl.Diagnostic {
+	rng := d.defRange
+	if attr, ok := d.attrs[name]; ok {
+		rng = attr.rng
+	}
+	return &hcl.Diagnostic{Severity: hcl.DiagError, Summary: summary, Detail: detail, Subject: &rng}
+}
@@ -0,0 +1,340 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Azure/tflint-helper/blockquery"
+	"github.com/Azure/tflint-helper/modulecontent"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-linters/tflint-plugin-sdk/helper"
+	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
+)
+
+func TestLoadFromFS(t *testing.T) {
+	testCases := []struct {
+		name     string
+		filename string
+		content  string
+		rules    []string
+		severity []tflint.Severity
+	}{
+		{
+			name:     "hcl",
+			filename: "rules.hcl",
+			content: `
+rule "storage_tls" {
+  link          = "https://example.com/tls"
+  resource_type = "Microsoft.Storage/storageAccounts"
+  query         = "properties.minimumTlsVersion"
+  compare       = "IsOneOf"
+  expected      = ["TLS1_2"]
+}
+
+rule "storage_retention" {
+  severity            = "warning"
+  preview_versions    = "deny"
+  resource_type       = "Microsoft.Storage/storageAccounts"
+  minimum_api_version = "2023-01-01"
+  query               = "properties.retentionDays"
+  compare             = "InRange"
+  expected            = [7, 365]
+  must_exist          = false
+}
+
+rule "subnet_names" {
+  severity           = "notice"
+  resource_type      = "Microsoft.Network/virtualNetworks"
+  query              = "properties.subnets.#.name"
+  compare            = "MatchesRegex"
+  expected           = "^snet-"
+  compare_each_match = true
+  unknown_values     = "ignore"
+}
+
+rule "sku" {
+  resource_type = "Microsoft.Network/publicIPAddresses"
+  query         = ""
+  compare       = "MatchesShape"
+  expected      = [{ sku = { name = "Standard" } }]
+}
+`,
+			rules:    []string{"storage_tls", "storage_retention", "subnet_names", "sku"},
+			severity: []tflint.Severity{tflint.ERROR, tflint.WARNING, tflint.NOTICE, tflint.ERROR},
+		},
+		{
+			name:     "yaml",
+			filename: "rules.yaml",
+			content: `
+rules:
+  - name: storage_tls
+    link: https://example.com/tls
+    resource_type: Microsoft.Storage/storageAccounts
+    query: properties.minimumTlsVersion
+    compare: IsOneOf
+    expected: [TLS1_2]
+  - name: storage_retention
+    severity: warning
+    resource_type: Microsoft.Storage/storageAccounts
+    query: properties.retentionDays
+    compare: InRange
+    expected: [7, 365]
+    must_exist: false
+`,
+			rules:    []string{"storage_tls", "storage_retention"},
+			severity: []tflint.Severity{tflint.ERROR, tflint.WARNING},
+		},
+		{
+			name:     "json",
+			filename: "rules.json",
+			content: `{
+  "rule": {
+    "storage_tls": {
+      "resource_type": "Microsoft.Storage/storageAccounts",
+      "query": "properties.minimumTlsVersion",
+      "compare": "IsOneOf",
+      "expected": ["TLS1_2"]
+    }
+  }
+}`,
+			rules:    []string{"storage_tls"},
+			severity: []tflint.Severity{tflint.ERROR},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, tc.filename, []byte(tc.content), os.ModePerm))
+			rules, diags := LoadFromFS(fs, tc.filename)
+			require.False(t, diags.HasErrors(), diags.Error())
+			require.Len(t, rules, len(tc.rules))
+			for i, r := range rules {
+				require.Equal(t, tc.rules[i], r.Name())
+				require.Equal(t, tc.severity[i], r.Severity())
+			}
+		})
+	}
+}
+
+func TestLoadFromFSErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		filename string
+		content  string
+		summary  string
+		pos      hcl.Pos // The expected start of the diagnostic, only the line and column are compared.
+	}{
+		{
+			name:     "invalid query",
+			filename: "rules.hcl",
+			content: `rule "a" {
+  resource_type = "Microsoft.Storage/storageAccounts"
+  query         = "properties."
+  compare       = "IsNotNull"
+}`,
+			summary: "Invalid query",
+			pos:     hcl.Pos{Line: 3, Column: 19},
+		},
+		{
+			name:     "unknown compare function",
+			filename: "rules.hcl",
+			content: `rule "a" {
+  resource_type = "Microsoft.Storage/storageAccounts"
+  query         = "properties.name"
+  compare       = "IsFoo"
+}`,
+			summary: "Unknown compare function",
+			pos:     hcl.Pos{Line: 4, Column: 19},
+		},
+		{
+			name:     "wrong number of bounds",
+			filename: "rules.hcl",
+			content: `rule "a" {
+  resource_type = "Microsoft.Storage/storageAccounts"
+  query         = "properties.retentionDays"
+  compare       = "InRange"
+  expected      = [7]
+}`,
+			summary: "Invalid expected values",
+			pos:     hcl.Pos{Line: 5, Column: 19},
+		},
+		{
+			name:     "invalid regex",
+			filename: "rules.hcl",
+			content: `rule "a" {
+  resource_type = "Microsoft.Storage/storageAccounts"
+  query         = "properties.name"
+  compare       = "MatchesRegex"
+  expected      = ["("]
+}`,
+			summary: "Invalid expected values",
+			pos:     hcl.Pos{Line: 5, Column: 19},
+		},
+		{
+			name:     "missing query",
+			filename: "rules.hcl",
+			content: `rule "a" {
+  resource_type = "Microsoft.Storage/storageAccounts"
+  compare       = "IsNotNull"
+}`,
+			summary: "Missing required argument",
+			pos:     hcl.Pos{Line: 1, Column: 10},
+		},
+		{
+			name:     "invalid severity",
+			filename: "rules.hcl",
+			content: `rule "a" {
+  severity      = "fatal"
+  resource_type = "Microsoft.Storage/storageAccounts"
+  query         = "properties.name"
+  compare       = "IsNotNull"
+}`,
+			summary: "Invalid severity",
+			pos:     hcl.Pos{Line: 2, Column: 19},
+		},
+		{
+			name:     "invalid preview policy",
+			filename: "rules.hcl",
+			content: `rule "a" {
+  resource_type    = "Microsoft.Storage/storageAccounts"
+  preview_versions = "never"
+  query            = "properties.name"
+  compare          = "IsNotNull"
+}`,
+			summary: "Invalid preview policy",
+			pos:     hcl.Pos{Line: 3, Column: 22},
+		},
+		{
+			name:     "invalid unknown value policy",
+			filename: "rules.hcl",
+			content: `rule "a" {
+  resource_type  = "Microsoft.Storage/storageAccounts"
+  unknown_values = "skip"
+  query          = "properties.name"
+  compare        = "IsNotNull"
+}`,
+			summary: "Invalid unknown value policy",
+			pos:     hcl.Pos{Line: 3, Column: 20},
+		},
+		{
+			name:     "duplicate rule",
+			filename: "rules.hcl",
+			content: `rule "a" {
+  resource_type = "Microsoft.Storage/storageAccounts"
+  query         = "properties.name"
+  compare       = "IsNotNull"
+}
+rule "a" {
+  resource_type = "Microsoft.Storage/storageAccounts"
+  query         = "properties.name"
+  compare       = "IsNotNull"
+}`,
+			summary: "Duplicate rule",
+			pos:     hcl.Pos{Line: 6, Column: 6},
+		},
+		{
+			name:     "yaml unknown compare function",
+			filename: "rules.yml",
+			content: `rules:
+  - name: a
+    resource_type: Microsoft.Storage/storageAccounts
+    query: properties.name
+    compare: IsFoo
+`,
+			summary: "Unknown compare function",
+			pos:     hcl.Pos{Line: 5, Column: 14},
+		},
+		{
+			name:     "yaml unsupported argument",
+			filename: "rules.yml",
+			content: `rules:
+  - name: a
+    resource_type: Microsoft.Storage/storageAccounts
+    query: properties.name
+    compare: IsNotNull
+    mustexist: true
+`,
+			summary: "Unsupported argument",
+			pos:     hcl.Pos{Line: 6, Column: 5},
+		},
+		{
+			name:     "yaml missing argument",
+			filename: "rules.yml",
+			content: `rules:
+  - name: a
+    query: properties.name
+    compare: IsNotNull
+`,
+			summary: "Missing required argument",
+			pos:     hcl.Pos{Line: 2, Column: 5},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, tc.filename, []byte(tc.content), os.ModePerm))
+			rules, diags := LoadFromFS(fs, tc.filename)
+			require.Nil(t, rules)
+			require.True(t, diags.HasErrors())
+			require.Equal(t, tc.summary, diags[0].Summary)
+			require.Equal(t, tc.filename, diags[0].Subject.Filename)
+			require.Equal(t, tc.pos.Line, diags[0].Subject.Start.Line)
+			require.Equal(t, tc.pos.Column, diags[0].Subject.Start.Column)
+		})
+	}
+}
+
+func TestLoadFromFSMissingFile(t *testing.T) {
+	_, diags := LoadFromFS(afero.NewMemMapFs(), "rules.hcl")
+	require.True(t, diags.HasErrors())
+}
+
+func TestLoadFromFSUnparsedApiVersionBound(t *testing.T) {
+	content := `
+resource "azapi_resource" "old" {
+	type = "Microsoft.Storage/storageAccounts@2023-04-30"
+	body = {
+		properties = {
+			minimumTlsVersion = "TLS1_0"
+		}
+	}
+}
+
+resource "azapi_resource" "new" {
+	type = "Microsoft.Storage/storageAccounts@2023-05-01"
+	body = {
+		properties = {
+			minimumTlsVersion = "TLS1_0"
+		}
+	}
+}`
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "rules.hcl", []byte(`
+rule "storage_tls" {
+  resource_type       = "Microsoft.Storage/storageAccounts"
+  minimum_api_version = "2023-05"
+  query               = "properties.minimumTlsVersion"
+  compare             = "IsOneOf"
+  expected            = ["TLS1_2"]
+}
+`), os.ModePerm))
+	loaded, diags := LoadFromFS(fs, "rules.hcl")
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.Len(t, loaded, 1)
+	built := NewAzApiRuleQueryMustExist("storage_tls", "", "Microsoft.Storage/storageAccounts", "2023-05", "", "properties.minimumTlsVersion", blockquery.IsOneOf, blockquery.NewStringResults("TLS1_2")...)
+
+	stub := gostub.Stub(&modulecontent.AppFs, mockFs(content))
+	defer stub.Reset()
+	for _, rule := range []tflint.Rule{loaded[0], built} {
+		runner := helper.TestRunner(t, map[string]string{"main.tf": content})
+		require.NoError(t, rule.Check(runner))
+		require.Len(t, runner.Issues, 1)
+		require.Equal(t, 15, runner.Issues[0].Range.Start.Line)
+	}
+}
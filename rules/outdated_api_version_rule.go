@@ -0,0 +1,207 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/tflint-helper/modulecontent"
+	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// timeNow returns the current time, it is a variable so that tests can stub it.
+var timeNow = time.Now
+
+// OutdatedApiVersionRule raises an issue for azapi resources whose `type` uses an outdated API version.
+// By default a version is outdated if the catalog has a newer stable version for the resource type.
+// Use WithMaxAgeMonths to instead flag versions older than a number of months.
+type OutdatedApiVersionRule struct {
+	tflint.DefaultRule // Embed the default rule to reuse its implementation
+	catalog            *ApiVersionCatalog
+	maxAgeMonths       int
+	link               string
+	ruleName           string
+	severity           tflint.Severity
+	blockLabels        []string
+	blockTypes         []string
+}
+
+var _ tflint.Rule = &OutdatedApiVersionRule{}
+var _ modulecontent.BlockFetcher = &OutdatedApiVersionRule{}
+var _ modulecontent.LabelOnesFetcher = &OutdatedApiVersionRule{}
+var _ modulecontent.BlockTypesFetcher = &OutdatedApiVersionRule{}
+
+// NewOutdatedApiVersionRule creates a rule that checks the API version of azapi resources against the catalog.
+// Resource types that are not in the catalog are not checked, not even for a missing or invalid API version.
+// The issue message suggests the newest stable version in the catalog.
+func NewOutdatedApiVersionRule(ruleName, link string, catalog *ApiVersionCatalog) *OutdatedApiVersionRule {
+	return &OutdatedApiVersionRule{
+		catalog:     catalog,
+		link:        link,
+		ruleName:    ruleName,
+		severity:    tflint.WARNING,
+		blockLabels: []string{"azapi_resource"},
+		blockTypes:  []string{"resource"},
+	}
+}
+
+// WithMaxAgeMonths makes the rule flag API versions that are older than the given number of months,
+// rather than those that are older than the newest stable version.
+// The catalog is only used to suggest a newer version and may be nil.
+func (r *OutdatedApiVersionRule) WithMaxAgeMonths(months int) *OutdatedApiVersionRule {
+	r.maxAgeMonths = months
+	return r
+}
+
+// WithSeverity sets the severity of the issues raised by the rule, the default is tflint.WARNING.
+func (r *OutdatedApiVersionRule) WithSeverity(severity tflint.Severity) *OutdatedApiVersionRule {
+	r.severity = severity
+	return r
+}
+
+// WithBlockLabels sets the azapi block types that the rule checks, the default is `azapi_resource`.
+// This function panics if no labels are given.
+func (r *OutdatedApiVersionRule) WithBlockLabels(labels ...string) *OutdatedApiVersionRule {
+	if len(labels) == 0 {
+		panic("WithBlockLabels requires at least one label")
+	}
+	r.blockLabels = labels
+	return r
+}
+
+// WithBlockTypes sets the types of block that the rule checks, the default is `resource`.
+// This function panics if no block types are given.
+func (r *OutdatedApiVersionRule) WithBlockTypes(blockTypes ...string) *OutdatedApiVersionRule {
+	if len(blockTypes) == 0 {
+		panic("WithBlockTypes requires at least one block type")
+	}
+	r.blockTypes = blockTypes
+	return r
+}
+
+func (r *OutdatedApiVersionRule) Link() string {
+	return r.link
+}
+
+func (r *OutdatedApiVersionRule) Enabled() bool {
+	return true
+}
+
+func (r *OutdatedApiVersionRule) Severity() tflint.Severity {
+	return r.severity
+}
+
+func (r *OutdatedApiVersionRule) Name() string {
+	return r.ruleName
+}
+
+func (r *OutdatedApiVersionRule) LabelOne() string {
+	return r.blockLabels[0]
+}
+
+func (r *OutdatedApiVersionRule) LabelOnes() []string {
+	return r.blockLabels
+}
+
+func (r *OutdatedApiVersionRule) LabelNames() []string {
+	return []string{"type", "name"}
+}
+
+func (r *OutdatedApiVersionRule) BlockType() string {
+	return r.blockTypes[0]
+}
+
+func (r *OutdatedApiVersionRule) BlockTypes() []string {
+	return r.blockTypes
+}
+
+func (r *OutdatedApiVersionRule) Attributes() []string {
+	return []string{"type"}
+}
+
+func (r *OutdatedApiVersionRule) Check(runner tflint.Runner) error {
+	ctx, resources, diags := modulecontent.FetchBlocks(r, runner)
+	if diags.HasErrors() {
+		return fmt.Errorf("could not get partial content: %s", diags)
+	}
+	for _, resource := range resources {
+		runner := blockKindRunner(runner, resource, len(r.blockLabels) > 1 || len(r.blockTypes) > 1)
+		typeAttr, typeAttrExists := resource.Body.Attributes["type"]
+		if !typeAttrExists {
+			continue
+		}
+		typeVal, diags := ctx.EvaluateExpr(typeAttr.Expr, cty.String)
+		if diags.HasErrors() {
+			return fmt.Errorf("could not evaluate type expression: %s", diags)
+		}
+		if !typeVal.IsKnown() || typeVal.IsNull() {
+			continue
+		}
+		resourceType, versionStr, found := strings.Cut(typeVal.AsString(), "@")
+		if !r.checksType(resourceType) {
+			continue
+		}
+		if !found {
+			runner.EmitIssue( // nolint: errcheck
+				r,
+				fmt.Sprintf("type `%s` does not have an API version", typeVal.AsString()),
+				typeAttr.Expr.Range(),
+			)
+			continue
+		}
+		version, err := ParseApiVersion(versionStr)
+		if err != nil {
+			runner.EmitIssue( // nolint: errcheck
+				r,
+				err.Error(),
+				typeAttr.Expr.Range(),
+			)
+			continue
+		}
+		if msg := r.outdatedMessage(resourceType, version); msg != "" {
+			runner.EmitIssue( // nolint: errcheck
+				r,
+				msg,
+				typeAttr.Expr.Range(),
+			)
+		}
+	}
+	return nil
+}
+
+// checksType reports whether the rule checks the resource type, which must be in the catalog unless the rule checks the age of versions.
+func (r *OutdatedApiVersionRule) checksType(resourceType string) bool {
+	if r.maxAgeMonths > 0 {
+		return true
+	}
+	return r.catalog != nil && len(r.catalog.Versions(resourceType)) > 0
+}
+
+// outdatedMessage returns the issue message if the API version of the resource type is outdated, or an empty string.
+func (r *OutdatedApiVersionRule) outdatedMessage(resourceType string, version ApiVersion) string {
+	var latest ApiVersion
+	hasLatest := false
+	if r.catalog != nil {
+		latest, hasLatest = r.catalog.LatestStable(resourceType)
+	}
+	suggestion := ""
+	if hasLatest && version.Compare(latest) < 0 {
+		suggestion = fmt.Sprintf(", the newest stable version is `%s`", latest)
+	}
+	if r.maxAgeMonths > 0 {
+		cutoff := timeNow().AddDate(0, -r.maxAgeMonths, 0)
+		released := time.Date(version.Year, time.Month(version.Month), version.Day, 0, 0, 0, 0, time.UTC)
+		if !released.Before(cutoff) {
+			return ""
+		}
+		return fmt.Sprintf("API version `%s` of `%s` is older than %d months%s", version, resourceType, r.maxAgeMonths, suggestion)
+	}
+	if suggestion == "" {
+		return ""
+	}
+	return fmt.Sprintf("API version `%s` of `%s` is outdated%s", version, resourceType, suggestion)
+}
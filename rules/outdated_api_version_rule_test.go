@@ -0,0 +1,160 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/tflint-helper/modulecontent"
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-linters/tflint-plugin-sdk/helper"
+	"github.com/terraform-linters/tflint-plugin-sdk/tflint"
+)
+
+func TestOutdatedApiVersionRule(t *testing.T) {
+	catalog, err := NewApiVersionCatalog(map[string][]string{
+		"Microsoft.Storage/storageAccounts": {"2022-09-01", "2023-01-01", "2023-05-01", "2024-01-01-preview"},
+	})
+	require.NoError(t, err)
+	testCases := []struct {
+		name     string
+		rule     tflint.Rule
+		content  string
+		expected helper.Issues
+	}{
+		{
+			name: "newest stable version",
+			rule: NewOutdatedApiVersionRule("test", "https://example.com", catalog),
+			content: `
+resource "azapi_resource" "test" {
+	type = "Microsoft.Storage/storageAccounts@2023-05-01"
+}`,
+			expected: helper.Issues{},
+		},
+		{
+			name: "newer preview version",
+			rule: NewOutdatedApiVersionRule("test", "https://example.com", catalog),
+			content: `
+resource "azapi_resource" "test" {
+	type = "Microsoft.Storage/storageAccounts@2024-01-01-preview"
+}`,
+			expected: helper.Issues{},
+		},
+		{
+			name: "older than newest stable version",
+			rule: NewOutdatedApiVersionRule("test", "https://example.com", catalog),
+			content: `
+resource "azapi_resource" "test" {
+	type = "Microsoft.Storage/storageAccounts@2023-01-01"
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewOutdatedApiVersionRule("test", "https://example.com", catalog),
+					Message: "API version `2023-01-01` of `Microsoft.Storage/storageAccounts` is outdated, the newest stable version is `2023-05-01`",
+				},
+			},
+		},
+		{
+			name: "resource type not in catalog",
+			rule: NewOutdatedApiVersionRule("test", "https://example.com", catalog),
+			content: `
+resource "azapi_resource" "test" {
+	type = "Microsoft.Test/things@2020-01-01"
+}`,
+			expected: helper.Issues{},
+		},
+		{
+			name: "older than max age",
+			rule: NewOutdatedApiVersionRule("test", "https://example.com", catalog).WithMaxAgeMonths(30),
+			content: `
+resource "azapi_resource" "test" {
+	type = "Microsoft.Storage/storageAccounts@2022-09-01"
+}
+
+resource "azapi_resource" "test2" {
+	type = "Microsoft.Storage/storageAccounts@2023-01-01"
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewOutdatedApiVersionRule("test", "https://example.com", catalog),
+					Message: "API version `2022-09-01` of `Microsoft.Storage/storageAccounts` is older than 30 months, the newest stable version is `2023-05-01`",
+				},
+			},
+		},
+		{
+			name: "older than max age without catalog",
+			rule: NewOutdatedApiVersionRule("test", "https://example.com", nil).WithMaxAgeMonths(12),
+			content: `
+resource "azapi_resource" "test" {
+	type = "Microsoft.Test/things@2024-01-01"
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewOutdatedApiVersionRule("test", "https://example.com", nil),
+					Message: "API version `2024-01-01` of `Microsoft.Test/things` is older than 12 months",
+				},
+			},
+		},
+		{
+			name: "data source",
+			rule: NewOutdatedApiVersionRule("test", "https://example.com", catalog).WithBlockTypes("resource", "data"),
+			content: `
+data "azapi_resource" "test" {
+	type = "Microsoft.Storage/storageAccounts@2022-09-01"
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewOutdatedApiVersionRule("test", "https://example.com", catalog),
+					Message: "data.azapi_resource: API version `2022-09-01` of `Microsoft.Storage/storageAccounts` is outdated, the newest stable version is `2023-05-01`",
+				},
+			},
+		},
+		{
+			name: "invalid version of type not in catalog",
+			rule: NewOutdatedApiVersionRule("test", "https://example.com", catalog),
+			content: `
+resource "azapi_resource" "test" {
+	type = "Microsoft.Test/things@latest"
+}
+
+resource "azapi_resource" "test2" {
+	type = "Microsoft.Test/things"
+}`,
+			expected: helper.Issues{},
+		},
+		{
+			name: "invalid version",
+			rule: NewOutdatedApiVersionRule("test", "https://example.com", catalog),
+			content: `
+resource "azapi_resource" "test" {
+	type = "Microsoft.Storage/storageAccounts@latest"
+}`,
+			expected: helper.Issues{
+				{
+					Rule:    NewOutdatedApiVersionRule("test", "https://example.com", catalog),
+					Message: "invalid API version `latest`, expected the form YYYY-MM-DD with an optional suffix",
+				},
+			},
+		},
+	}
+
+	filename := "main.tf"
+	for _, c := range testCases {
+		tc := c
+		t.Run(tc.name, func(t *testing.T) {
+			runner := helper.TestRunner(t, map[string]string{filename: tc.content})
+			stubs := gostub.Stub(&modulecontent.AppFs, mockFs(tc.content))
+			stubs.Stub(&timeNow, func() time.Time {
+				return time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+			})
+			defer stubs.Reset()
+			if err := tc.rule.Check(runner); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			helper.AssertIssuesWithoutRange(t, tc.expected, runner.Issues)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"github.com/Azure/tflint-helper/blockquery"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/terraform-linters/tflint-plugin-sdk/hclext"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// rangeForPath traces the path through the object and tuple constructors of the attribute expression
+// and returns the source range of the item that the path refers to, e.g. `sku = { name = "Basic" }`.
+// If the path can only be partly traced, e.g. because part of the body is a variable reference,
+// the range of the deepest item found is returned. If the path cannot be traced at all, the attribute range is returned.
+func rangeForPath(attr *hclext.Attribute, path cty.Path) hcl.Range {
+	rng := attr.Range
+	expr := unwrapBodyExpr(attr.Expr)
+	for _, step := range path {
+		switch e := expr.(type) {
+		case *hclsyntax.ObjectConsExpr:
+			item, ok := objectConsItem(e, step)
+			if !ok {
+				return rng
+			}
+			rng = hcl.RangeBetween(item.KeyExpr.Range(), item.ValueExpr.Range())
+			expr = unwrapBodyExpr(item.ValueExpr)
+		case *hclsyntax.TupleConsExpr:
+			i, ok := tupleConsIndex(e, step)
+			if !ok {
+				return rng
+			}
+			rng = e.Exprs[i].Range()
+			expr = unwrapBodyExpr(e.Exprs[i])
+		default:
+			return rng
+		}
+	}
+	return rng
+}
+
+// rangeForMatches returns the source range of the deepest item that is common to all the matches.
+func rangeForMatches(attr *hclext.Attribute, matches []blockquery.Match) hcl.Range {
+	if len(matches) == 0 {
+		return attr.Range
+	}
+	common := matches[0].Path
+	for _, m := range matches[1:] {
+		n := 0
+		for n < len(common) && n < len(m.Path) && m.Path[:n+1].Equals(common[:n+1]) {
+			n++
+		}
+		common = common[:n]
+	}
+	return rangeForPath(attr, common)
+}
+
+// unwrapBodyExpr removes parentheses and a `jsonencode()` call from a body expression.
+func unwrapBodyExpr(expr hcl.Expression) hcl.Expression {
+	for {
+		switch e := expr.(type) {
+		case *hclsyntax.ParenthesesExpr:
+			expr = e.Expression
+		case *hclsyntax.FunctionCallExpr:
+			if e.Name != "jsonencode" || len(e.Args) != 1 {
+				return expr
+			}
+			expr = e.Args[0]
+		default:
+			return expr
+		}
+	}
+}
+
+// objectConsItem returns the item of the object constructor with the key named by the path step.
+func objectConsItem(e *hclsyntax.ObjectConsExpr, step cty.PathStep) (hclsyntax.ObjectConsItem, bool) {
+	var name string
+	switch s := step.(type) {
+	case cty.GetAttrStep:
+		name = s.Name
+	case cty.IndexStep:
+		if s.Key.Type() != cty.String {
+			return hclsyntax.ObjectConsItem{}, false
+		}
+		name = s.Key.AsString()
+	default:
+		return hclsyntax.ObjectConsItem{}, false
+	}
+	for _, item := range e.Items {
+		key, diags := item.KeyExpr.Value(nil)
+		if diags.HasErrors() || !key.IsKnown() || key.IsNull() || key.Type() != cty.String {
+			continue
+		}
+		if key.AsString() == name {
+			return item, true
+		}
+	}
+	return hclsyntax.ObjectConsItem{}, false
+}
+
+// tupleConsIndex returns the index of the tuple constructor element named by the path step.
+func tupleConsIndex(e *hclsyntax.TupleConsExpr, step cty.PathStep) (int, bool) {
+	s, ok := step.(cty.IndexStep)
+	if !ok || s.Key.Type() != cty.Number {
+		return 0, false
+	}
+	i, _ := s.Key.AsBigFloat().Int64()
+	if i < 0 || int(i) >= len(e.Exprs) {
+		return 0, false
+	}
+	return int(i), true
+}
@@ -0,0 +1,117 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/Azure/tflint-helper/blockquery"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-linters/tflint-plugin-sdk/hclext"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestRangeForPath(t *testing.T) {
+	src := `{
+  sku = { name = "Basic" }
+  "quoted key" = 1
+  properties = {
+    subnets = [
+      { name = "a" },
+      { name = "b" },
+    ]
+    ref = var.ref
+  }
+}`
+	testCases := []struct {
+		desc string
+		src  string
+		path cty.Path
+		want string
+	}{
+		{
+			desc: "object item",
+			src:  src,
+			path: cty.GetAttrPath("sku"),
+			want: `sku = { name = "Basic" }`,
+		},
+		{
+			desc: "nested object item",
+			src:  src,
+			path: cty.GetAttrPath("sku").GetAttr("name"),
+			want: `name = "Basic"`,
+		},
+		{
+			desc: "quoted key",
+			src:  src,
+			path: cty.GetAttrPath("quoted key"),
+			want: `"quoted key" = 1`,
+		},
+		{
+			desc: "tuple element",
+			src:  src,
+			path: cty.GetAttrPath("properties").GetAttr("subnets").IndexInt(1).GetAttr("name"),
+			want: `name = "b"`,
+		},
+		{
+			desc: "reference stops tracing",
+			src:  src,
+			path: cty.GetAttrPath("properties").GetAttr("ref").GetAttr("name"),
+			want: `ref = var.ref`,
+		},
+		{
+			desc: "missing key returns deepest item",
+			src:  src,
+			path: cty.GetAttrPath("properties").GetAttr("notExist"),
+			want: "properties = {\n    subnets = [\n      { name = \"a\" },\n      { name = \"b\" },\n    ]\n    ref = var.ref\n  }",
+		},
+		{
+			desc: "jsonencode",
+			src:  `jsonencode({ sku = { name = "Basic" } })`,
+			path: cty.GetAttrPath("sku").GetAttr("name"),
+			want: `name = "Basic"`,
+		},
+		{
+			desc: "not an object constructor",
+			src:  `var.body`,
+			path: cty.GetAttrPath("sku"),
+			want: `body = var.body`,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			attr, file := parseBodyAttr(t, tC.src)
+			rng := rangeForPath(attr, tC.path)
+			require.Equal(t, tC.want, string(rng.SliceBytes(file)))
+		})
+	}
+}
+
+func TestRangeForMatches(t *testing.T) {
+	attr, file := parseBodyAttr(t, `{
+  subnets = [
+    { name = "a" },
+    { name = "b" },
+  ]
+}`)
+	matches := []blockquery.Match{
+		{Path: cty.GetAttrPath("subnets").IndexInt(0).GetAttr("name")},
+		{Path: cty.GetAttrPath("subnets").IndexInt(1).GetAttr("name")},
+	}
+	rng := rangeForMatches(attr, matches)
+	require.Equal(t, "subnets = [\n    { name = \"a\" },\n    { name = \"b\" },\n  ]", string(rng.SliceBytes(file)))
+	rng = rangeForMatches(attr, matches[1:])
+	require.Equal(t, `name = "b"`, string(rng.SliceBytes(file)))
+}
+
+// parseBodyAttr parses `body = <src>` and returns the attribute and the file source.
+func parseBodyAttr(t *testing.T, src string) (*hclext.Attribute, []byte) {
+	file := []byte("body = " + src)
+	f, diags := hclsyntax.ParseConfig(file, "main.tf", hcl.InitialPos)
+	require.False(t, diags.HasErrors(), diags.Error())
+	attr := f.Body.(*hclsyntax.Body).Attributes["body"]
+	return &hclext.Attribute{Name: attr.Name, Expr: attr.Expr, Range: attr.Range()}, file
+}